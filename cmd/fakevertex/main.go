@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/digkill/veo-telegram-bot/internal/fakevertex"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+)
+
+// Заглушка Vertex AI для локального запуска бота:
+//
+//	VERTEX_BASE_URL=http://localhost:8089
+//	GCE_METADATA_HOST=localhost:8089   (токен берётся у заглушки)
+//	GCS_BASE_URL=http://localhost:8089 (для output.mode=gcs)
+func main() {
+	addr := flag.String("addr", utils.GetEnv("FAKE_VERTEX_ADDR", ":8089"), "адрес, на котором слушает заглушка")
+	scenario := flag.String("scenario", utils.GetEnv("FAKE_VERTEX_SCENARIO", fakevertex.ScenarioSuccess),
+		"сценарий по умолчанию: success, rai_block, quota, malformed, timeout")
+	polls := flag.Int("polls", 2, "сколько опросов операция остаётся незавершённой")
+	flag.Parse()
+
+	srv := fakevertex.New(fakevertex.Scenario{Kind: *scenario, PollsUntilDone: *polls})
+
+	log.Printf("fake vertex слушает %s, сценарий %s (polls=%d)", *addr, *scenario, *polls)
+	log.Fatal(http.ListenAndServe(*addr, logRequests(srv)))
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
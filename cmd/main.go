@@ -2,7 +2,11 @@ package main
 
 import (
 	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/generator"
 	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/metrics"
 	"log"
 
 	"github.com/digkill/veo-telegram-bot/internal/bot"
@@ -15,6 +19,9 @@ import (
 func main() {
 	cache.Init()
 	logger.Init()
+	config.MustLoad()
+	// регионы Vertex AI и токены Google: ошибки конфигурации — сразу при старте
+	generator.Init()
 	// подключаем БД
 	db.Connect()
 
@@ -26,14 +33,33 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// удаление видео по сроку хранения (MEDIA_RETENTION)
+	mediastore.StartSweeper()
+
+	// метрики для операторов (/debug/vars на METRICS_ADDR)
+	metrics.Serve()
+
+	// воркеры генерации: не больше MAX_CONCURRENT_GENERATIONS задач одновременно
+	bot.StartWorkers(api)
+
+	// продолжаем генерации, прерванные перезапуском
+	bot.ResumeJobs(api)
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	// очень важно: включаем нужные типы обновлений
 	u.AllowedUpdates = []string{"message", "callback_query", "pre_checkout_query"}
 
+	// обновления обрабатываются параллельно, но не больше MAX_CONCURRENT_UPDATES сразу:
+	// при всплеске следующие ждут в канале обновлений, а не копятся горутинами
+	sem := make(chan struct{}, utils.GetEnvInt("MAX_CONCURRENT_UPDATES", 32))
 	updates := api.GetUpdatesChan(u)
 	for update := range updates {
-		go bot.HandleUpdate(api, update)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			bot.HandleUpdate(api, update)
+		}()
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+)
+
+// Перенос видео и превью между хранилищами, например с диска в S3/MinIO:
+//
+//	go run ./cmd/mediamigrate -from local -to s3
+//
+// Переносятся файлы всех неудалённых строк user_logs; уже перенесённые объекты пропускаются,
+// поэтому команду можно перезапускать после сбоя.
+func main() {
+	from := flag.String("from", "local", "исходное хранилище: local или s3")
+	to := flag.String("to", "s3", "целевое хранилище: local или s3")
+	deleteSource := flag.Bool("delete", false, "удалять объекты из исходного хранилища после переноса")
+	dryRun := flag.Bool("dry-run", false, "только показать, что будет перенесено")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatal("❌ -from и -to совпадают")
+	}
+	src := mediastore.MustNew(*from)
+	dst := mediastore.MustNew(*to)
+
+	db.Connect()
+	ctx := context.Background()
+
+	var copied, skipped, failed int
+	var afterID int64
+	for {
+		files, err := repository.ListMedia(afterID, 500)
+		if err != nil {
+			log.Fatalf("❌ Не удалось прочитать user_logs: %v", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, f := range files {
+			afterID = f.LogID
+			for _, path := range []string{f.VideoPath, f.ThumbPath} {
+				key, ok := mediastore.Key(path)
+				if !ok {
+					continue
+				}
+				switch err := migrate(ctx, src, dst, key, *deleteSource, *dryRun); {
+				case err == errSkipped:
+					skipped++
+				case err != nil:
+					failed++
+					log.Printf("⚠️ %s: %v", key, err)
+				default:
+					copied++
+					log.Printf("→ %s", key)
+				}
+			}
+		}
+	}
+
+	log.Printf("готово: перенесено %d, пропущено %d, ошибок %d", copied, skipped, failed)
+}
+
+// errSkipped — объект уже в целевом хранилище или отсутствует в исходном
+var errSkipped = errors.New("skipped")
+
+func migrate(ctx context.Context, src, dst mediastore.Store, key string, deleteSource, dryRun bool) error {
+	exists, err := src.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errSkipped
+	}
+	copied, err := dst.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if copied && !deleteSource {
+		return errSkipped
+	}
+	if dryRun {
+		return nil
+	}
+
+	if !copied {
+		if err := mediastore.Copy(ctx, src, dst, key); err != nil {
+			return err
+		}
+	}
+	if deleteSource {
+		if err := src.Delete(ctx, key); err != nil && !errors.Is(err, mediastore.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+)
+
+const (
+	fetchTimeout      = 30 * time.Second
+	refreshRetryDelay = 30 * time.Second
+)
+
+// не чаще одного фонового обновления за этот интервал, даже если токен живёт совсем недолго
+const minRefreshWait = 5 * time.Second
+
+// CachedSource отдаёт закешированный токен и заранее обновляет его в фоне
+type CachedSource struct {
+	src  Source
+	skew time.Duration // за сколько до истечения токен считается просроченным
+	// minWait — наименьшая пауза фонового обновления (minRefreshWait)
+	minWait time.Duration
+
+	mu      sync.Mutex
+	token   *Token
+	fetched time.Time // когда получен token: от этого момента считается срок его жизни
+	once    sync.Once
+}
+
+func NewCachedSource(src Source, skew time.Duration) *CachedSource {
+	return &CachedSource{src: src, skew: skew, minWait: minRefreshWait}
+}
+
+// Token возвращает действующий access token; подходит как vertex.TokenFunc
+func (c *CachedSource) Token(ctx context.Context) (string, error) {
+	c.once.Do(func() { go c.refreshLoop() })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid(time.Now()) {
+		return c.token.AccessToken, nil
+	}
+
+	token, err := c.src.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.fetched = token, time.Now()
+	return token.AccessToken, nil
+}
+
+// valid — токен ещё можно отдавать: до истечения больше skew, но не меньше четверти срока жизни
+// (токены metadata-сервера GCE бывают выданы на 5–10 минут, и целый skew их бы сразу «просрочил»)
+func (c *CachedSource) valid(now time.Time) bool {
+	return c.token != nil && now.Before(c.before(c.skew, 4))
+}
+
+// refreshAt — когда обновлять токен в фоне: за 2*skew до истечения, но не раньше середины срока жизни
+func (c *CachedSource) refreshAt() time.Time {
+	return c.before(2*c.skew, 2)
+}
+
+// before — момент за margin до истечения токена, но отступ не больше 1/div срока жизни
+func (c *CachedSource) before(margin time.Duration, div int64) time.Time {
+	if limit := c.token.Expiry.Sub(c.fetched) / time.Duration(div); margin > limit {
+		margin = max(limit, 0)
+	}
+	return c.token.Expiry.Add(-margin)
+}
+
+// refreshLoop обновляет токен до того, как его придётся ждать в запросе
+func (c *CachedSource) refreshLoop() {
+	failed := false
+	for {
+		c.mu.Lock()
+		wait := refreshRetryDelay
+		if c.token != nil && !failed {
+			wait = time.Until(c.refreshAt())
+		}
+		c.mu.Unlock()
+
+		// пауза есть всегда: иначе токен, который сразу «пора обновлять», запрашивался бы без остановки
+		time.Sleep(max(wait, c.minWait))
+
+		c.mu.Lock()
+		due := c.token == nil || !time.Now().Before(c.refreshAt())
+		c.mu.Unlock()
+		if !due {
+			// токен успели обновить в Token
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		token, err := c.src.Token(ctx)
+		cancel()
+		failed = err != nil
+		if err != nil {
+			logger.LogError("auth", map[string]interface{}{
+				"type":  "token_refresh_failed",
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		c.mu.Lock()
+		c.token, c.fetched = token, time.Now()
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shortSource выдаёт токены, которые живут ttl — меньше, чем двойной skew кеша
+type shortSource struct {
+	ttl   time.Duration
+	calls atomic.Int32
+}
+
+func (s *shortSource) Token(ctx context.Context) (*Token, error) {
+	s.calls.Add(1)
+	return &Token{AccessToken: "short", Expiry: time.Now().Add(s.ttl)}, nil
+}
+
+func TestCachedSourceShortLivedToken(t *testing.T) {
+	src := &shortSource{ttl: 400 * time.Millisecond}
+	c := NewCachedSource(src, 5*time.Minute)
+	c.minWait = 50 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := src.calls.Load(); n != 1 {
+		t.Fatalf("токен запрошен %d раз, ожидался 1: короткий токен должен кешироваться", n)
+	}
+
+	// фон обновляет токен к середине срока жизни (~200 мс), а не непрерывно
+	time.Sleep(time.Second)
+	n := src.calls.Load()
+	if n < 2 {
+		t.Errorf("токен не обновлялся в фоне: %d запросов", n)
+	}
+	if n > 10 {
+		t.Errorf("токен обновлялся %d раз за секунду — цикл обновления не делает пауз", n)
+	}
+
+	if _, err := c.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if after := src.calls.Load(); after-n > 1 {
+		t.Errorf("Token запросил новый токен %d раз вместо кешированного", after-n)
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultMetadataHost = "169.254.169.254"
+
+// MetadataSource берёт токен default-сервисного аккаунта у metadata-сервера
+type MetadataSource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewMetadataSource принимает host[:port] или полный URL; пустое значение — стандартный адрес GCE
+func NewMetadataSource(host string) *MetadataSource {
+	if host == "" {
+		host = defaultMetadataHost
+	}
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host
+	}
+	return &MetadataSource{
+		BaseURL:    strings.TrimRight(host, "/"),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *MetadataSource) Token(ctx context.Context) (*Token, error) {
+	url := s.BaseURL + "/computeMetadata/v1/instance/service-accounts/default/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeTokenResponse(resp)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultTokenURL = "https://oauth2.googleapis.com/token"
+
+// serviceAccountKey — нужные поля JSON-ключа сервисного аккаунта
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ServiceAccountSource выпускает токены по JWT bearer flow (RFC 7523)
+type ServiceAccountSource struct {
+	Email      string
+	KeyID      string
+	Key        *rsa.PrivateKey
+	TokenURL   string
+	Scope      string
+	HTTPClient *http.Client
+}
+
+// NewServiceAccountSource читает JSON-ключ; tokenURL переопределяет token_uri из ключа
+func NewServiceAccountSource(keyPath, tokenURL string) (*ServiceAccountSource, error) {
+	raw, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ключ сервисного аккаунта: %w", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("невалидный JSON ключа сервисного аккаунта: %w", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" {
+		return nil, fmt.Errorf("файл %s не является ключом сервисного аккаунта", keyPath)
+	}
+
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenURL == "" {
+		tokenURL = key.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultTokenURL
+	}
+
+	return &ServiceAccountSource{
+		Email:      key.ClientEmail,
+		KeyID:      key.PrivateKeyID,
+		Key:        privateKey,
+		TokenURL:   tokenURL,
+		Scope:      CloudPlatformScope,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func parsePrivateKey(pemData string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, fmt.Errorf("private_key не в формате PEM")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private_key не является RSA-ключом")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать private_key: %w", err)
+	}
+	return key, nil
+}
+
+// Token подписывает JWT-assertion и обменивает его на access token
+func (s *ServiceAccountSource) Token(ctx context.Context) (*Token, error) {
+	assertion, err := s.assertion(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeTokenResponse(resp)
+}
+
+func (s *ServiceAccountSource) assertion(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if s.KeyID != "" {
+		header["kid"] = s.KeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   s.Email,
+		"scope": s.Scope,
+		"aud":   s.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(headerJSON) + "." + enc.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("не удалось подписать JWT: %w", err)
+	}
+
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
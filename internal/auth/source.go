@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// CloudPlatformScope — OAuth scope, достаточный для вызовов Vertex AI
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Token — access token вместе с моментом истечения
+type Token struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// Source выпускает новые токены; кеширование делает CachedSource
+type Source interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// tokenResponse — ответ OAuth token endpoint и metadata-сервера
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+func decodeTokenResponse(resp *http.Response) (*Token, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint вернул HTTP %d: %s", resp.StatusCode, body)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("невалидный ответ token endpoint: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint не вернул access_token")
+	}
+
+	return &Token{
+		AccessToken: tr.AccessToken,
+		Expiry:      time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// FromEnv выбирает источник токенов по окружению:
+// GOOGLE_APPLICATION_CREDENTIALS — JSON-ключ сервисного аккаунта,
+// иначе — metadata-сервер (GCE, Cloud Run, GKE)
+func FromEnv() (Source, error) {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return NewServiceAccountSource(path, os.Getenv("GOOGLE_TOKEN_URL"))
+	}
+	return NewMetadataSource(os.Getenv("GCE_METADATA_HOST")), nil
+}
+
+// MustFromEnv — как FromEnv, но завершает процесс при ошибке конфигурации
+func MustFromEnv() Source {
+	src, err := FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Не удалось настроить источник токенов Google: %v", err)
+	}
+	return src
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/generator"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminIDs — Telegram ID операторов из ADMIN_IDS (через запятую)
+var adminIDs = parseAdminIDs(os.Getenv("ADMIN_IDS"))
+
+func parseAdminIDs(spec string) map[int64]bool {
+	ids := map[int64]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func isAdmin(userID int64) bool {
+	return adminIDs[userID]
+}
+
+// showQueueStats — /queue: очередь генераций, состояние регионов Vertex AI и экономия на готовых результатах
+func showQueueStats(bot *tgbotapi.BotAPI, chatID int64) {
+	s := pool.stats()
+	lines := []string{
+		"📊 Очередь генераций",
+		fmt.Sprintf("Воркеры: %d/%d заняты", s.Running, s.Workers),
+		fmt.Sprintf("В очереди: %d, самая старая ждёт %s", s.Depth, seconds(s.OldestWaitSeconds)),
+		fmt.Sprintf("Запущено: %d, ожидание в среднем %s, максимум %s", s.Dequeued, seconds(s.AverageWaitSeconds), seconds(s.MaxWaitSeconds)),
+		"",
+		"🌍 Регионы Vertex AI",
+	}
+	for _, r := range generator.RegionStatus() {
+		state := "✅"
+		if !r.Healthy {
+			state = fmt.Sprintf("⛔ до %s", r.DownUntil.Format("15:04:05"))
+		}
+		lines = append(lines, fmt.Sprintf("%s (вес %d): %s, ошибок подряд %d", r.Name, r.Weight, state, r.Failures))
+	}
+	if hits, saved, err := repository.CacheHitStats(); err == nil {
+		lines = append(lines, "", fmt.Sprintf("♻️ Готовые результаты выданы %d раз, сэкономлено %d кр.", hits, saved))
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, strings.Join(lines, "\n")))
+}
+
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+}
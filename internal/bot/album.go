@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько ждать остальные сообщения альбома после последнего полученного
+const albumWait = 1500 * time.Millisecond
+
+// больше двух кадров (первый и последний) модели не принимают
+const maxAlbumImages = 2
+
+type album struct {
+	messages []*tgbotapi.Message
+	timer    *time.Timer
+}
+
+var (
+	albumsMu sync.Mutex
+	albums   = map[string]*album{}
+)
+
+// bufferAlbum копит сообщения одного MediaGroupID и обрабатывает их разом, когда поток затихнет
+func bufferAlbum(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	albumsMu.Lock()
+	defer albumsMu.Unlock()
+
+	groupID := msg.MediaGroupID
+	a, ok := albums[groupID]
+	if !ok {
+		a = &album{}
+		albums[groupID] = a
+		a.timer = time.AfterFunc(albumWait, func() { flushAlbum(bot, groupID) })
+	} else {
+		a.timer.Reset(albumWait)
+	}
+	a.messages = append(a.messages, msg)
+}
+
+// flushAlbum превращает альбом в один запрос: подпись — промт, фото — первый и последний кадр
+func flushAlbum(bot *tgbotapi.BotAPI, groupID string) {
+	albumsMu.Lock()
+	a := albums[groupID]
+	delete(albums, groupID)
+	albumsMu.Unlock()
+	if a == nil || len(a.messages) == 0 {
+		return
+	}
+
+	// обновления обрабатываются параллельно — восстанавливаем порядок альбома
+	sort.Slice(a.messages, func(i, j int) bool { return a.messages[i].MessageID < a.messages[j].MessageID })
+	first := a.messages[0]
+
+	var text string
+	var fileIDs []string
+	for _, msg := range a.messages {
+		if text == "" {
+			text = msg.Caption
+		}
+		fileID, err := imageFileID(msg)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(first.Chat.ID, imageErrorMessage(err)))
+			return
+		}
+		if fileID != "" {
+			fileIDs = append(fileIDs, fileID)
+		}
+	}
+
+	if len(fileIDs) > maxAlbumImages {
+		bot.Send(tgbotapi.NewMessage(first.Chat.ID, fmt.Sprintf(
+			"⚠️ В альбоме %d картинок. Пришли одну (первый кадр) или две (первый и последний кадр).", len(fileIDs))))
+		return
+	}
+
+	handlePrompt(bot, first.Chat.ID, first.From.ID, text, fileIDs)
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/generator"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Политика возврата при отмене: задача из очереди в Vertex AI не уходила, её кредиты возвращаются целиком;
+// за уже запущенную генерацию возвращается CANCEL_REFUND_PERCENT процентов (по умолчанию 100, 0 — ничего).
+var cancelRefundPercent = parsePercent(os.Getenv("CANCEL_REFUND_PERCENT"), 100)
+
+func parsePercent(s string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 || n > 100 {
+		return def
+	}
+	return n
+}
+
+func cancelButton(jobID int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("❌ Отменить", fmt.Sprintf("cancel_%d", jobID))
+}
+
+// handleCancelCallback отменяет задачу по кнопке в сообщении о ходе генерации
+func handleCancelCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	jobID, _ := strconv.ParseInt(strings.TrimPrefix(cb.Data, "cancel_"), 10, 64)
+
+	job, queued, ok := pool.cancelJob(jobID, cb.From.ID)
+	if !ok {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "Генерация уже завершена"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, "⏹ Отменяю…"))
+
+	// запущенную задачу завершит runJob, когда увидит отменённый контекст
+	if queued {
+		cancelJob(bot, job)
+	}
+}
+
+// cancelJob завершает отменённую задачу: останавливает операцию, возвращает кредиты по политике и пишет generation_cancelled
+func cancelJob(bot *tgbotapi.BotAPI, job *models.GenerationJob) {
+	started := job.OperationName != ""
+	if started {
+		_ = generator.Cancel(job.UserID, job.Region, job.OperationName)
+	}
+
+	if err := repository.FailJob(job.ID, models.JobCancelled, "отменено пользователем"); err != nil {
+		logger.LogError("job_cancel", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+	job.Status = models.JobCancelled
+	finishJobStatus(bot, job, nil)
+
+	refund := job.Credits
+	if started {
+		refund = job.Credits * cancelRefundPercent / 100
+	}
+	if refund > 0 {
+		if err := repository.RefundCredits(job.UserID, refund); err != nil {
+			logger.LogError("job_refund", map[string]interface{}{
+				"job_id":  job.ID,
+				"user_id": job.UserID,
+				"credits": refund,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	repository.LogAction(job.UserID, "generation_cancelled", job.Prompt, false, "")
+	logger.LogInfo("job_cancelled", map[string]interface{}{
+		"job_id":  job.ID,
+		"user_id": job.UserID,
+		"started": started,
+		"refund":  refund,
+	})
+
+	switch {
+	case refund == job.Credits:
+		bot.Send(tgbotapi.NewMessage(job.ChatID, fmt.Sprintf("⏹ Генерация отменена.\n\n💰 %d кр. возвращены на баланс.", refund)))
+	case refund > 0:
+		bot.Send(tgbotapi.NewMessage(job.ChatID, fmt.Sprintf("⏹ Генерация отменена.\n\n💰 Возвращено %d из %d кр.: генерация уже была запущена.", refund, job.Credits)))
+	default:
+		bot.Send(tgbotapi.NewMessage(job.ChatID, "⏹ Генерация отменена. Кредиты не возвращаются: генерация уже была запущена."))
+	}
+}
@@ -0,0 +1,213 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Многошаговые диалоги: бот запоминает шаг в Redis (cache.SetState), и следующее сообщение пользователя
+// попадает в обработчик этого шага, даже если пользователь не ответил на сообщение бота и бот перезапускался.
+
+// handleCancel — /cancel: выйти из незаконченного шага диалога (сам шаг сбрасывает роутер перед любой командой)
+func handleCancel(r *request) {
+	r.reply("Ок, отменил. Можешь отправить новый промт.")
+}
+
+// askEmail просит email для чека у пользователя userID; pack — пакет кредитов, покупка которого продолжится после ввода email
+func askEmail(bot *tgbotapi.BotAPI, chatID, userID int64, text, pack string) {
+	var data map[string]string
+	if pack != "" {
+		data = map[string]string{"package": pack}
+	}
+	// шаг хранится по отправителю: в группе следующее сообщение ищется по from.ID, а не по чату
+	if err := cache.SetState(userID, cache.StateAwaitingEmail, data); err != nil {
+		logger.LogError("conversation_state", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	bot.Send(msg)
+}
+
+// handleEmailInput — шаг awaiting_email: сохраняем email и продолжаем покупку
+func handleEmailInput(r *request) {
+	email := strings.TrimSpace(r.message().Text)
+	if !strings.Contains(email, "@") {
+		r.reply("⚠️ Это не похоже на email, попробуй ещё раз или отправь /cancel.")
+		return
+	}
+
+	if err := repository.UpdateUserContact(r.from.ID, email, ""); err != nil {
+		r.reply("⚠️ Не удалось сохранить email.")
+		return
+	}
+	cache.ClearState(r.from.ID)
+
+	if pack := r.conv.Data["package"]; pack != "" {
+		r.reply("✅ Email сохранён! Отправляю счёт.")
+		sendInvoice(r.bot, r.chatID, r.from.ID, pack)
+		return
+	}
+	r.reply("✅ Email сохранён! Теперь можешь выбрать пакет.")
+	showBuyOptions(r.bot, r.chatID, r.from.ID)
+}
+
+func promptEditButton(userID int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить промт", fmt.Sprintf("promptedit_%d", userID))
+}
+
+// handlePromptEditCallback — кнопка «Изменить промт»: ждём новый текст (шаг awaiting_prompt_edit)
+func handlePromptEditCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	userID := cb.From.ID
+
+	text, imageBase64, _, err := cache.GetPromptData(userID)
+	if err != nil || text == "" {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Запрос устарел — отправь промт ещё раз"))
+		return
+	}
+
+	data := map[string]string{"message_id": strconv.Itoa(cb.Message.MessageID)}
+	// картинки уже вписаны в кадр — формат после правки должен остаться прежним
+	if imageBase64 != "" {
+		if _, params, err := prompt.Parse(text); err == nil {
+			data["aspect_ratio"] = params.AspectRatio
+		}
+	}
+	if err := cache.SetState(userID, cache.StatePromptEdit, data); err != nil {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Ошибка при сохранении запроса"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, ""))
+
+	msg := tgbotapi.NewMessage(cb.Message.Chat.ID, "✏️ Пришли новый текст промта с тегами — картинки и выбранный стиль останутся. Передумал — /cancel.\n\nСейчас: "+text)
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	bot.Send(msg)
+}
+
+// handlePromptEditInput — шаг awaiting_prompt_edit: заменяем текст запроса и заново показываем подтверждение
+func handlePromptEditInput(r *request) {
+	userID := r.from.ID
+	text := strings.TrimSpace(r.message().Text)
+	if text == "" {
+		r.reply("⚠️ Пришли новый промт текстом или отправь /cancel")
+		return
+	}
+
+	_, imageBase64, lastFrameBase64, err := cache.GetPromptData(userID)
+	if err != nil {
+		cache.ClearState(userID)
+		r.reply("⚠️ Запрос устарел — отправь промт ещё раз")
+		return
+	}
+	if aspect := r.conv.Data["aspect_ratio"]; aspect != "" {
+		if _, params, err := prompt.Parse(text); err == nil && params.AspectRatio != aspect {
+			r.reply(fmt.Sprintf("⚠️ Картинка уже подготовлена под формат %s — убери тег формата или отправь запрос с картинкой заново", aspect))
+			return
+		}
+	}
+
+	preset, err := selectedPreset(userID)
+	if err != nil {
+		// выбранный стиль отключили — показываем запрос без него
+		cache.SetPromptPreset(userID, 0)
+	}
+	summary, markup, err := confirmationView(userID, text, []string{imageBase64, lastFrameBase64}, preset)
+	if err != nil {
+		// шаг остаётся: пользователь пришлёт исправленный промт
+		r.reply(paramsErrorMessage(err))
+		return
+	}
+	if err := cache.UpdatePromptText(userID, text); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		r.reply("⚠️ Ошибка при сохранении запроса")
+		return
+	}
+	cache.ClearState(userID)
+
+	// у старого подтверждения убираем кнопки, чтобы не путаться
+	if id, _ := strconv.Atoi(r.conv.Data["message_id"]); id != 0 {
+		r.bot.Request(tgbotapi.NewEditMessageReplyMarkup(r.chatID, id, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	}
+	msg := tgbotapi.NewMessage(r.chatID, summary)
+	msg.ReplyMarkup = markup
+	r.bot.Send(msg)
+}
+
+// handleSupportCommand — /support: следующее сообщение пользователя уйдёт операторам (шаг awaiting_support)
+func handleSupportCommand(r *request) {
+	if len(adminIDs) == 0 {
+		r.reply("⚠️ Поддержка сейчас недоступна")
+		return
+	}
+	if r.args != "" {
+		forwardToSupport(r, r.args)
+		return
+	}
+	if err := cache.SetState(r.from.ID, cache.StateAwaitingSupport, nil); err != nil {
+		r.reply("⚠️ Ошибка при сохранении запроса")
+		return
+	}
+	r.reply("🆘 Опиши вопрос одним сообщением — я передам его операторам. Передумал — /cancel.")
+}
+
+// handleSupportInput — шаг awaiting_support: вопрос пришёл отдельным сообщением
+func handleSupportInput(r *request) {
+	text := strings.TrimSpace(r.message().Text)
+	if text == "" {
+		text = strings.TrimSpace(r.message().Caption)
+	}
+	if text == "" {
+		r.reply("⚠️ Опиши вопрос текстом или отправь /cancel")
+		return
+	}
+	cache.ClearState(r.from.ID)
+	forwardToSupport(r, text)
+}
+
+func forwardToSupport(r *request, text string) {
+	name := r.from.UserName
+	if name == "" {
+		name = r.from.FirstName
+	}
+	note := fmt.Sprintf("🆘 Вопрос от %s (id %d):\n\n%s\n\nОтветить: /answer %d текст", name, r.from.ID, text, r.from.ID)
+	for adminID := range adminIDs {
+		r.bot.Send(tgbotapi.NewMessage(adminID, note))
+	}
+	logger.LogInfo("support_request", map[string]interface{}{
+		"user_id": r.from.ID,
+	})
+	repository.LogAction(r.from.ID, "support_request", text, true, "")
+	r.reply("✅ Передал вопрос в поддержку — ответ придёт сюда")
+}
+
+// handleAnswerCommand — /answer <id пользователя> <текст>: ответ оператора на вопрос в поддержку
+func handleAnswerCommand(r *request) {
+	fields := strings.Fields(r.args)
+	if len(fields) < 2 {
+		r.reply("Формат: /answer <id пользователя> <текст>")
+		return
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		r.reply("Формат: /answer <id пользователя> <текст>")
+		return
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(r.args, fields[0]))
+	if _, err := r.bot.Send(tgbotapi.NewMessage(userID, "💬 Ответ поддержки:\n\n"+text)); err != nil {
+		r.reply("⚠️ Не удалось отправить ответ: " + err.Error())
+		return
+	}
+	r.reply("✅ Ответ отправлен")
+}
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/metrics"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько процентов цены берётся за повторную выдачу готового видео (по умолчанию 0 — бесплатно)
+var dedupPricePercent = parsePercent(os.Getenv("DEDUP_PRICE_PERCENT"), 0)
+
+func init() {
+	metrics.Func("result_cache", func() interface{} {
+		hits, saved, err := repository.CacheHitStats()
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]int{"hits": hits, "credits_saved": saved}
+	})
+}
+
+// dedupKey — адрес результата: нормализованный промт, хеши картинок, модель и параметры.
+// Без #seed генерация недетерминирована — возвращается "", и запрос не кэшируется.
+func dedupKey(modelID, cleanPrompt string, params prompt.GenerationParams, imageBase64, lastFrameBase64 string) string {
+	if params.Seed == nil {
+		return ""
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, part := range []string{
+		modelID,
+		strings.Join(strings.Fields(strings.ToLower(cleanPrompt)), " "),
+		string(paramsJSON),
+		hashString(imageBase64),
+		hashString(lastFrameBase64),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashString(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// dedupPrice — цена повторной выдачи результата
+func dedupPrice(r *models.CachedResult) int {
+	return r.Credits * dedupPricePercent / 100
+}
+
+// findCachedResult ищет готовый результат для запроса, который пользователь собирается подтвердить
+func findCachedResult(userID int64, modelID, cleanPrompt string, params prompt.GenerationParams, imageBase64, lastFrameBase64 string) *models.CachedResult {
+	key := dedupKey(modelID, cleanPrompt, params, imageBase64, lastFrameBase64)
+	if key == "" {
+		return nil
+	}
+	r, err := repository.FindCachedResult(userID, key)
+	if err != nil {
+		logger.LogError("result_cache", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return nil
+	}
+	return r
+}
+
+func dedupButton(r *models.CachedResult) tgbotapi.InlineKeyboardButton {
+	label := "♻️ Прислать готовое — бесплатно"
+	if price := dedupPrice(r); price > 0 {
+		label = fmt.Sprintf("♻️ Прислать готовое — %d кр.", price)
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("dedup_%d", r.ID))
+}
+
+// rememberResult кэширует доставленный результат детерминированного запроса
+func rememberResult(job *models.GenerationJob) {
+	if job.ChainID != 0 || job.StoryID != 0 {
+		return
+	}
+	var params prompt.GenerationParams
+	if err := json.Unmarshal([]byte(job.Params), &params); err != nil {
+		return
+	}
+	key := dedupKey(job.ModelID, job.Prompt, params, job.ImageBase64, job.LastFrameBase64)
+	if key == "" {
+		return
+	}
+	for _, v := range job.Videos {
+		if v.FileID == "" {
+			return
+		}
+	}
+
+	err := repository.SaveCachedResult(&models.CachedResult{
+		UserID:  job.UserID,
+		Key:     key,
+		JobID:   job.ID,
+		ModelID: job.ModelID,
+		Credits: job.Credits,
+		Videos:  job.Videos,
+	})
+	if err != nil {
+		logger.LogError("result_cache", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// handleDedupCallback присылает готовый результат вместо новой генерации
+func handleDedupCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	cacheID, _ := strconv.ParseInt(strings.TrimPrefix(cb.Data, "dedup_"), 10, 64)
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	r, err := repository.GetCachedResult(cacheID)
+	if err != nil || r.UserID != userID {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Готовое видео не найдено"))
+		return
+	}
+	// запрос забирается атомарно (GETDEL): если его уже взяло «Подтвердить» или повторное нажатие, выдавать нечего.
+	// Если выдать не получилось, запрос возвращается — кнопки под сообщением снова работают.
+	claimed, err := cache.TakePromptRequest(userID)
+	if err != nil || claimed == "" {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Запрос уже обработан"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, "♻️ Отправляю готовое видео"))
+
+	price := dedupPrice(r)
+	if price > 0 {
+		if err := repository.SubtractCredits(userID, price); err != nil {
+			restorePrompt(userID, claimed)
+			if errors.Is(err, repository.ErrInsufficientCredits) {
+				bot.Send(tgbotapi.NewMessage(chatID, "😢 Недостаточно кредитов. Пополни баланс через /buy и нажми кнопку ещё раз"))
+			} else {
+				bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось списать кредиты — попробуй ещё раз"))
+			}
+			return
+		}
+	}
+
+	if err := sendVideos(bot, &models.GenerationJob{UserID: userID, ChatID: chatID, Videos: r.Videos}); err != nil {
+		logger.LogError("cache_hit", map[string]interface{}{
+			"cache_id": r.ID,
+			"user_id":  userID,
+			"error":    err.Error(),
+		})
+		if price > 0 {
+			_ = repository.RefundCredits(userID, price)
+		}
+		restorePrompt(userID, claimed)
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось отправить готовое видео — попробуй ещё раз или подтверди генерацию заново"))
+		return
+	}
+
+	saved := r.Credits - price
+	if err := repository.RecordCacheHit(r.ID, userID, price, saved); err != nil {
+		logger.LogError("cache_hit", map[string]interface{}{
+			"cache_id": r.ID,
+			"error":    err.Error(),
+		})
+	}
+	repository.LogAction(userID, "cache_hit", "", true, "")
+	logger.LogInfo("cache_hit", map[string]interface{}{
+		"cache_id": r.ID,
+		"user_id":  userID,
+		"charged":  price,
+		"saved":    saved,
+	})
+
+	balance, _ := repository.GetBalance(userID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Это видео уже генерировалось — сэкономлено %d кр. Остаток: %d кр.", saved, balance)))
+}
+
+// restorePrompt возвращает запрос, забранный кнопкой, которая не смогла его обработать
+func restorePrompt(userID int64, claimed string) {
+	if err := cache.RestorePromptRequest(userID, claimed); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	dedupUser     = 7
+	pendingPrompt = `{"prompt":"a red fox #seed42","preset_id":3}`
+)
+
+// useDedup готовит повторную выдачу: результат за 10 кр. (берётся половина), баланс balance
+// и сохранённый запрос, который ждёт подтверждения
+func useDedup(t *testing.T, balance int) (*fakeRedis, *fakeDB) {
+	t.Helper()
+	prev := dedupPricePercent
+	dedupPricePercent = 50
+	t.Cleanup(func() { dedupPricePercent = prev })
+
+	conn, fake := openFakeDB(func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, "FROM result_cache"):
+			return row(int64(1), int64(dedupUser), "key", int64(5), "veo-test", int64(10),
+				`[{"path":"storage/video.mp4","file_id":"cached"}]`, time.Now())
+		case strings.Contains(query, "SELECT credits FROM users"):
+			return row(int64(balance))
+		}
+		return nil
+	})
+	prevDB := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = prevDB })
+
+	rdb := useRedis(t)
+	rdb.values["prompt:7"] = pendingPrompt
+	rdb.expires["prompt:7"] = time.Now().Add(10 * time.Minute)
+	return rdb, fake
+}
+
+func pressDedup(bot *tgbotapi.BotAPI) {
+	handleDedupCallback(bot, &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: dedupUser},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: dedupUser}},
+		Data:    "dedup_1",
+	})
+}
+
+// запрос, который не удалось выдать, возвращается целиком и снова ждёт подтверждения
+func assertPromptRestored(t *testing.T, rdb *fakeRedis) {
+	t.Helper()
+	val, ttl, ok := rdb.get("prompt:7")
+	if !ok {
+		t.Fatal("запрос потерян — подтвердить его заново нельзя")
+	}
+	if val != pendingPrompt {
+		t.Errorf("восстановлен запрос %s, ожидался %s", val, pendingPrompt)
+	}
+	if ttl <= 0 {
+		t.Error("восстановленный запрос без TTL")
+	}
+}
+
+func TestDedupInsufficientCreditsKeepsPrompt(t *testing.T) {
+	rdb, _ := useDedup(t, 0)
+	bot, tg := newTestBot(t)
+
+	pressDedup(bot)
+
+	if sent := tg.texts("sendVideo"); len(sent) != 0 {
+		t.Errorf("видео отправлено без оплаты: %d", len(sent))
+	}
+	if msgs := tg.texts("sendMessage"); len(msgs) != 1 || !strings.Contains(msgs[0], "Недостаточно кредитов") {
+		t.Errorf("сообщения %q, ожидалось о нехватке кредитов", msgs)
+	}
+	assertPromptRestored(t, rdb)
+
+	// кнопка снова работает: повторное нажатие забирает запрос, а не отвечает «уже обработан»
+	pressDedup(bot)
+	if answers := tg.texts("answerCallbackQuery"); len(answers) != 2 || !strings.Contains(answers[1], "Отправляю") {
+		t.Errorf("ответы на кнопку %q, ожидалась повторная попытка выдачи", answers)
+	}
+}
+
+func TestDedupSendFailureRefundsAndKeepsPrompt(t *testing.T) {
+	rdb, fake := useDedup(t, 100)
+	bot, tg := newTestBot(t)
+	tg.fail["sendVideo"] = true
+
+	pressDedup(bot)
+
+	if fake.executed("UPDATE users SET credits = credits - ?") != 1 {
+		t.Fatal("кредиты не списаны")
+	}
+	if fake.executed("UPDATE users SET credits = credits + ?") != 1 {
+		t.Error("кредиты за неотправленное видео не возвращены")
+	}
+	if fake.executed("INSERT INTO cache_hits") != 0 {
+		t.Error("неудачная выдача записана как сэкономленная")
+	}
+	if msgs := tg.texts("sendMessage"); len(msgs) != 1 || !strings.Contains(msgs[0], "Не удалось отправить") {
+		t.Errorf("сообщения %q, ожидалось об ошибке отправки", msgs)
+	}
+	assertPromptRestored(t, rdb)
+}
+
+func TestDedupSuccessConsumesPrompt(t *testing.T) {
+	rdb, fake := useDedup(t, 100)
+	bot, tg := newTestBot(t)
+
+	pressDedup(bot)
+
+	if len(tg.texts("sendVideo")) != 1 {
+		t.Fatal("готовое видео не отправлено")
+	}
+	if fake.executed("INSERT INTO cache_hits") != 1 {
+		t.Error("выдача не записана в cache_hits")
+	}
+	if _, _, ok := rdb.get("prompt:7"); ok {
+		t.Error("запрос остался после выдачи — его можно подтвердить второй раз")
+	}
+}
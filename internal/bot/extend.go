@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/media"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько раз можно продлить одно видео (не считая исходного ролика)
+var maxExtensions = utils.GetEnvInt("MAX_EXTENSIONS", 3)
+
+const chainBusyMessage = "⏳ Это видео уже продлевается — дождись готового продолжения и продли его"
+
+func extendButton(logID int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("➕ Продлить", fmt.Sprintf("extend_%d", logID))
+}
+
+// handleExtendCallback — нажата кнопка «Продлить»: просим описать продолжение
+func handleExtendCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+	logID, _ := strconv.ParseInt(strings.TrimPrefix(cb.Data, "extend_"), 10, 64)
+
+	base, err := resolveExtension(userID, logID)
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Видео не найдено"))
+		return
+	}
+	segments := base.segments
+	if segments > maxExtensions {
+		bot.Request(tgbotapi.NewCallback(cb.ID, fmt.Sprintf("Это видео уже продлено %d раз — больше нельзя", maxExtensions)))
+		return
+	}
+
+	if err := cache.SetState(userID, cache.StateExtendPrompt, map[string]string{"log_id": strconv.FormatInt(logID, 10)}); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Ошибка при сохранении запроса"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, ""))
+
+	model := userModel(userID)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"✏️ Опиши, что происходит дальше — продолжение начнётся с последнего кадра.\n"+
+			"Можно добавить теги, например #6s. Сегмент %d из %d, %d кр. на %s. Передумал — /cancel.",
+		segments+1, maxExtensions+1, model.Price, model.DisplayName()))
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	bot.Send(msg)
+}
+
+// handleExtendPrompt — на шаге awaiting_extend_prompt пришёл текст продолжения:
+// берём последний кадр и запускаем генерацию
+func handleExtendPrompt(r *request) {
+	bot, msg := r.bot, r.message()
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	logID, err := strconv.ParseInt(r.conv.Data["log_id"], 10, 64)
+	if err != nil {
+		cache.ClearState(userID)
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Запрос на продление устарел — нажми «Продлить» ещё раз"))
+		return
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Напиши текстом, что происходит дальше, или /cancel"))
+		return
+	}
+	// шаг пройден: при ошибке ниже пользователь нажмёт «Продлить» заново
+	cache.ClearState(userID)
+
+	base, err := resolveExtension(userID, logID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Видео не найдено"))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось загрузить цепочку видео"))
+		return
+	}
+	if base.segments > maxExtensions {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Это видео уже продлено %d раз — больше нельзя", maxExtensions)))
+		return
+	}
+	chain, videoPath := base.chain, base.videoPath
+	if chain != nil {
+		// окончательно это проверяется при создании задачи (repository.CreateChainJob), здесь — чтобы не брать кадр зря
+		if busy, err := repository.ChainBusy(chain.ID); err == nil && busy {
+			bot.Send(tgbotapi.NewMessage(chatID, chainBusyMessage))
+			return
+		}
+	}
+
+	imageBase64, info, err := lastFrameBase64(videoPath)
+	if err != nil {
+		logger.LogError("extend_frame", map[string]interface{}{
+			"user_id": userID,
+			"video":   videoPath,
+			"error":   err.Error(),
+		})
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось взять последний кадр видео"))
+		return
+	}
+
+	// продолжение сохраняет ориентацию исходного ролика, если формат не задан явно
+	if info.Height > info.Width && !strings.Contains(text, "#16:9") && !strings.Contains(text, "#9:16") {
+		text += " #9:16"
+	}
+
+	if chain == nil {
+		if chain, err = repository.CreateChain(userID, chatID, logID, videoPath, base.segments); err != nil {
+			logger.LogError("chain_create", map[string]interface{}{
+				"user_id": userID,
+				"log_id":  logID,
+				"error":   err.Error(),
+			})
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось начать цепочку видео"))
+			return
+		}
+	}
+
+	startGeneration(bot, chatID, userID, text, imageBase64, "", chain.ID, nil)
+}
+
+// extendBase — видео, от которого продолжается генерация
+type extendBase struct {
+	chain     *models.VideoChain // nil — цепочку нужно начать от этого видео
+	segments  int                // сколько сегментов уже в видео (1 — обычное видео без продолжений)
+	videoPath string
+}
+
+// resolveExtension находит видео logID среди цепочек. Последняя склейка продолжает свою цепочку;
+// промежуточная (её с тех пор продлили) начинает новую, но счёт сегментов идёт от её позиции —
+// иначе кнопка «Продлить» под старым сообщением обходила бы MAX_EXTENSIONS.
+func resolveExtension(userID, logID int64) (*extendBase, error) {
+	chain, position, err := repository.GetChainBySegmentLog(userID, logID)
+	if err != nil {
+		return nil, err
+	}
+	if chain != nil && position == chain.Segments {
+		return &extendBase{chain: chain, segments: chain.Segments, videoPath: chain.VideoPath}, nil
+	}
+
+	videoPath, err := repository.GetLogVideo(userID, logID)
+	if err != nil {
+		return nil, err
+	}
+	base := &extendBase{segments: 1, videoPath: videoPath}
+	if chain != nil {
+		base.segments = position
+	}
+	return base, nil
+}
+
+func lastFrameBase64(videoPath string) (string, *media.Info, error) {
+	ctx := context.Background()
+	if err := mediastore.Localize(ctx, videoPath); err != nil {
+		return "", nil, err
+	}
+	info, err := media.Probe(ctx, videoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	frame, err := media.LastFrame(ctx, videoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(frame)
+
+	data, err := os.ReadFile(frame)
+	if err != nil {
+		return "", nil, err
+	}
+	return base64.StdEncoding.EncodeToString(data), info, nil
+}
+
+// extendChain приклеивает новый сегмент задачи к цепочке и возвращает склеенное видео.
+// Повторный вызов после перезапуска не клеит сегмент второй раз.
+func extendChain(ctx context.Context, job *models.GenerationJob) ([]models.JobVideo, error) {
+	chain, err := repository.GetChain(job.ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("цепочка %d не найдена: %w", job.ChainID, err)
+	}
+
+	done, err := repository.ChainHasJob(chain.ID, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := mediastore.Localize(ctx, chain.VideoPath); err != nil {
+		return nil, fmt.Errorf("видео цепочки недоступно: %w", err)
+	}
+	if done {
+		info, _ := media.Process(ctx, chain.VideoPath)
+		return []models.JobVideo{jobVideo(chain.VideoPath, chain.LogID, info)}, nil
+	}
+	if chain.Segments > maxExtensions {
+		return nil, fmt.Errorf("видео уже продлено %d раз — больше нельзя", maxExtensions)
+	}
+
+	segment := job.Videos[0]
+	out := strings.TrimSuffix(segment.Path, ".mp4") + "_chain.mp4"
+	if err := media.Concat(ctx, out, chain.VideoPath, segment.Path); err != nil {
+		return nil, fmt.Errorf("не удалось склеить видео: %w", err)
+	}
+
+	info, err := media.Process(ctx, out)
+	if err != nil {
+		logger.LogError("chain_postprocess", map[string]interface{}{
+			"job_id": job.ID,
+			"file":   out,
+			"error":  err.Error(),
+		})
+	}
+
+	logID, err := repository.LogGeneration(job.UserID, job.Prompt, out)
+	if err != nil {
+		return nil, err
+	}
+	thumb := ""
+	if info != nil {
+		_ = repository.SetLogMedia(logID, info)
+		thumb = info.ThumbPath
+	}
+	mediastore.Persist(ctx, out, thumb)
+	// сам сегмент пользователь отдельно не получает — он живёт только в склейке
+	_ = repository.SetLogTier(segment.LogID, models.TierSegment)
+
+	if err := repository.AppendChainSegment(chain.ID, job.ID, chain.Segments, segment.LogID, segment.Path, logID, out); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("цепочка %d не найдена", chain.ID)
+		}
+		return nil, err
+	}
+	return []models.JobVideo{jobVideo(out, logID, info)}, nil
+}
+
+func jobVideo(path string, logID int64, info *media.Info) models.JobVideo {
+	v := models.JobVideo{Path: path, LogID: logID}
+	if info != nil {
+		v.Thumb, v.Width, v.Height, v.Duration = info.ThumbPath, info.Width, info.Height, info.Duration
+	}
+	return v
+}
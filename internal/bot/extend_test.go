@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const extendUser = 7
+
+// segmentRef — видео logID в цепочке chain на позиции position
+type segmentRef struct {
+	chain, segments, current int64
+	position                 int64
+}
+
+// useChains подменяет базу: цепочки из refs и видео всех их строк в user_logs
+func useChains(t *testing.T, refs map[int64]segmentRef) {
+	t.Helper()
+	conn, _ := openFakeDB(func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, "FROM chain_segments s JOIN video_chains c"):
+			ref, ok := refs[args[1].(int64)]
+			if !ok || args[0].(int64) != extendUser {
+				return nil
+			}
+			now := time.Now()
+			return row(ref.chain, int64(extendUser), int64(extendUser), ref.current,
+				fmt.Sprintf("storage/chain_%d.mp4", ref.chain), ref.segments, now, now, ref.position)
+		case strings.Contains(query, "FROM user_logs"):
+			if args[1].(int64) != extendUser {
+				return nil
+			}
+			return row(fmt.Sprintf("storage/video_%d.mp4", args[0].(int64)))
+		}
+		return nil
+	})
+	prev := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = prev })
+}
+
+func setMaxExtensions(t *testing.T, n int) {
+	prev := maxExtensions
+	maxExtensions = n
+	t.Cleanup(func() { maxExtensions = prev })
+}
+
+func pressExtend(t *testing.T, logID int64) string {
+	t.Helper()
+	bot, tg := newTestBot(t)
+	handleExtendCallback(bot, &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: extendUser},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: extendUser}},
+		Data:    fmt.Sprintf("extend_%d", logID),
+	})
+	answers := tg.texts("answerCallbackQuery")
+	if len(answers) != 1 {
+		t.Fatalf("ответов на кнопку: %d, ожидался один", len(answers))
+	}
+	return answers[0]
+}
+
+// цепочка 1 продлена до предела: 10 → 20 → 30 → 40; цепочка 2 начата от промежуточного 30 и продлена до 50
+var fullChains = map[int64]segmentRef{
+	10: {chain: 1, segments: 4, current: 40, position: 1},
+	20: {chain: 1, segments: 4, current: 40, position: 2},
+	30: {chain: 2, segments: 4, current: 50, position: 3},
+	40: {chain: 1, segments: 4, current: 40, position: 4},
+	50: {chain: 2, segments: 4, current: 50, position: 4},
+}
+
+func TestExtendIntermediateCountsFromPosition(t *testing.T) {
+	setMaxExtensions(t, 3)
+	useChains(t, fullChains)
+
+	base, err := resolveExtension(extendUser, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.chain != nil || base.segments != 2 || base.videoPath != "storage/video_20.mp4" {
+		t.Errorf("промежуточное видео: %+v, ожидалась новая цепочка от 2 сегментов", base)
+	}
+
+	base, err = resolveExtension(extendUser, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.chain == nil || base.chain.ID != 1 || base.segments != 4 {
+		t.Errorf("последняя склейка: %+v, ожидалась цепочка 1 из 4 сегментов", base)
+	}
+
+	base, err = resolveExtension(extendUser, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.chain != nil || base.segments != 1 {
+		t.Errorf("обычное видео: %+v, ожидался 1 сегмент без цепочки", base)
+	}
+}
+
+func TestExtendIntermediatePastLimit(t *testing.T) {
+	setMaxExtensions(t, 3)
+	useChains(t, fullChains)
+
+	// продолжение, начатое от промежуточного видео, упирается в тот же лимит
+	if answer := pressExtend(t, 50); !strings.Contains(answer, "больше нельзя") {
+		t.Errorf("продление сверх лимита через промежуточное видео не отклонено: %q", answer)
+	}
+
+	// лимит уменьшили: промежуточное видео из 2 сегментов продлевать уже нельзя
+	setMaxExtensions(t, 1)
+	if answer := pressExtend(t, 20); !strings.Contains(answer, "больше нельзя") {
+		t.Errorf("промежуточное видео сверх лимита не отклонено: %q", answer)
+	}
+}
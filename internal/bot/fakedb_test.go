@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB — драйвер database/sql для тестов без MySQL: запоминает запросы, а строки выборок
+// отдаёт функция rows (nil — пустая выборка)
+type fakeDB struct {
+	mu      sync.Mutex
+	queries []string
+	lastID  int64
+	rows    func(query string, args []driver.Value) *fakeRows
+}
+
+func openFakeDB(rows func(query string, args []driver.Value) *fakeRows) (*sql.DB, *fakeDB) {
+	f := &fakeDB{rows: rows}
+	return sql.OpenDB(f), f
+}
+
+// executed — сколько выполненных запросов содержит substr
+func (f *fakeDB) executed(substr string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, q := range f.queries {
+		if strings.Contains(q, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.lastID++
+	return fakeResult(s.db.lastID), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.mu.Unlock()
+	if s.db.rows != nil {
+		if rows := s.db.rows(s.query, args); rows != nil {
+			return rows, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+// fakeResult — ID вставленной строки; затронута всегда одна строка
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+// fakeRows — выборка из заранее заданных строк
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// row — выборка из одной строки; колонки нумеруются, имена database/sql не нужны
+func row(values ...driver.Value) *fakeRows {
+	columns := make([]string, len(values))
+	for i := range columns {
+		columns[i] = "c" + string(rune('0'+i))
+	}
+	return &fakeRows{columns: columns, values: [][]driver.Value{values}}
+}
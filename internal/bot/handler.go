@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/digkill/veo-telegram-bot/internal/cache"
-	"github.com/digkill/veo-telegram-bot/internal/generator"
 	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
 	"github.com/digkill/veo-telegram-bot/internal/repository"
-	"github.com/digkill/veo-telegram-bot/internal/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"os"
 	"strconv"
@@ -16,12 +15,23 @@ import (
 
 const welcomeMessage = `👋 Привет! Я Veo Telegram Bot — твой AI-помощник по генерации видео.
 
-🎥 Просто отправь мне текст (можешь с картинкой), и я создам видео.
+🎥 Просто отправь мне текст (можешь с картинкой — фото или файлом JPEG/PNG/WebP), и я создам видео.
 
-📏 Укажи формат:
-• Пример: *Кот на пляже на закате #9:16*
-• Поддержка: #9:16, #16:9
+📏 Укажи параметры тегами:
+• Пример: *Кот на пляже на закате #9:16 #8s*
+• Формат: #9:16, #16:9
+• Длительность: #4s … #8s
+• Качество: #720p, #1080p
+• Без звука: #noaudio
+• Повторяемость: #seed=42
+• Чего избегать: #neg:размытый текст
+• Картинка не в формате кадра: #crop — обрезать, #pad — добавить поля
+• Две картинки альбомом — первый и последний кадр (если модель умеет интерполяцию)
 
+🎞️ Нужно несколько вариантов? Добавь #x2, #x3 или #x4 — каждый вариант оплачивается отдельно.
+➕ Под готовым видео есть кнопка «Продлить» — опиши, что дальше, и я допишу продолжение (оплата за каждый сегмент).
+
+🤖 Напиши /model, чтобы выбрать модель.
 💳 Напиши /buy, чтобы пополнить кредиты.
 📖 Напиши /help, чтобы узнать все команды.
 `
@@ -32,6 +42,11 @@ const helpMessage = `📖 Список команд:
 /help — показать это меню  
 /balance — твой текущий баланс  
 /buy — купить кредиты  
+/promo — активировать промокод  
+/model — выбрать модель генерации  
+/story — раскадровка: несколько сцен в одном видео  
+/support — написать в поддержку  
+/cancel — отменить текущий шаг (ввод email, правку промта и т. п.)  
 /ping — проверить статус бота
 
 💬 Просто отправь текст (можешь с картинкой), например:
@@ -40,188 +55,218 @@ const helpMessage = `📖 Список команд:
 🎞️ Через минуту ты получишь AI-видео!
 `
 
+// routes — команды, кнопки и шаги диалогов бота. Middleware выполняются по порядку до обработчика:
+// паника не роняет бота, обновление попадает в лог, частота запросов ограничена,
+// пользователь заведён в users и не заблокирован.
+var routes = newRouter(recoverPanics, logUpdates, rateLimit, registerUser, rejectBlocked)
+
+func init() {
+	routes.command("start", handleStart)
+	routes.command("help", func(r *request) { sendMarkdown(r.bot, r.chatID, helpMessage) })
+	routes.command("ping", func(r *request) { r.reply("🏓 Бот на связи") })
+	routes.command("balance", showBalance)
+	routes.command("buy", func(r *request) { showBuyOptions(r.bot, r.chatID, r.from.ID) })
+	routes.command("model", func(r *request) { showModelOptions(r.bot, r.chatID, r.from.ID) })
+	routes.command("story", handleStoryCommand)
+	routes.command("support", handleSupportCommand)
+	routes.command("promo", handlePromoCommand)
+	routes.command("cancel", handleCancel)
+	routes.command("queue", func(r *request) { showQueueStats(r.bot, r.chatID) }, adminOnly)
+	routes.command("presets", func(r *request) { showPresets(r.bot, r.chatID) }, adminOnly)
+	routes.command("preset_add", func(r *request) { handlePresetAdd(r.bot, r.chatID, r.args) }, adminOnly)
+	routes.command("preset_del", func(r *request) { handlePresetDel(r.bot, r.chatID, r.args) }, adminOnly)
+	routes.command("promo_add", func(r *request) { handlePromoAdd(r.bot, r.chatID, r.args) }, adminOnly)
+	routes.command("answer", handleAnswerCommand, adminOnly)
+
+	routes.state(cache.StateAwaitingEmail, handleEmailInput)
+	routes.state(cache.StatePromptEdit, handlePromptEditInput)
+	routes.state(cache.StateExtendPrompt, handleExtendPrompt)
+	routes.state(cache.StateStoryScript, handleStoryScript)
+	routes.state(cache.StateAwaitingSupport, handleSupportInput)
+	routes.state(cache.StateAwaitingPromo, handlePromoInput)
+
+	routes.callback("confirm_", onCallback(handleConfirmCallback), ownerOnly)
+	routes.callback("preset_", onCallback(handlePresetCallback))
+	routes.callback("promptedit_", onCallback(handlePromptEditCallback), ownerOnly)
+	routes.callback("dedup_", onCallback(handleDedupCallback))
+	routes.callback("cancel_", onCallback(handleCancelCallback))
+	routes.callback("model_", onCallback(handleModelCallback))
+	routes.callback("storyconfirm_", onCallback(handleStoryConfirm), ownerOnly)
+	routes.callback("storyretry_", onCallback(handleStoryRetry))
+	routes.callback("extend_", onCallback(handleExtendCallback))
+	routes.callback("fav_", onCallback(handleFavoriteCallback))
+	routes.callback("buy_", onCallback(handleBuyCallback))
+
+	routes.fallback = handleText
+	routes.payment = func(r *request) { handlePayment(r.bot, r.message()) }
+	routes.preCheckout = answerPreCheckout
+}
+
+// HandleUpdate обрабатывает одно обновление от Telegram; main вызывает его в отдельной горутине
 func HandleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	logger.LogUpdate(update)
+	routes.dispatch(bot, update)
+}
 
-	if update.Message != nil {
-		handleMessage(bot, update.Message)
-	}
+// onCallback адаптирует обработчик кнопки к маршрутизатору
+func onCallback(h func(*tgbotapi.BotAPI, *tgbotapi.CallbackQuery)) handlerFunc {
+	return func(r *request) { h(r.bot, r.callback()) }
+}
 
-	if update.CallbackQuery != nil {
-		logger.LogCallback(update.CallbackQuery)
-		handleCallback(bot, update.CallbackQuery)
-	}
+func sendMarkdown(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
 
-	if update.PreCheckoutQuery != nil {
-		resp := tgbotapi.PreCheckoutConfig{
-			PreCheckoutQueryID: update.PreCheckoutQuery.ID,
-			OK:                 true,
-		}
-		bot.Send(resp)
+// handleStart — /start; параметр из ссылки t.me/<bot>?start=<payload> пишется в лог для аналитики
+func handleStart(r *request) {
+	if r.args != "" {
+		logger.LogInfo("start_payload", map[string]interface{}{
+			"user_id": r.from.ID,
+			"payload": r.args,
+		})
 	}
+	sendMarkdown(r.bot, r.chatID, welcomeMessage)
+}
 
-	if update.Message != nil && update.Message.SuccessfulPayment != nil {
-		handlePayment(bot, update.Message)
+func showBalance(r *request) {
+	balance, err := repository.GetBalance(r.from.ID)
+	if err != nil {
+		r.reply("⚠️ Ошибка при получении баланса")
+		return
 	}
+	r.reply(fmt.Sprintf("💰 У тебя %d кредитов.", balance))
 }
 
-func handleMessage(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
-	logger.LogMessage(msg)
+// handleText — сообщение без команды: промт с картинкой или без
+func handleText(r *request) {
+	msg := r.message()
 
-	if msg.SuccessfulPayment != nil {
+	// фото из альбома приходят отдельными сообщениями — собираем их в один запрос
+	if msg.MediaGroupID != "" {
+		bufferAlbum(r.bot, msg)
 		return
 	}
 
-	chatID := msg.Chat.ID
+	fileID, err := imageFileID(msg)
+	if err != nil {
+		r.reply(imageErrorMessage(err))
+		return
+	}
 	text := msg.Text
-	userID := msg.From.ID
-	username := msg.From.UserName
-
-	// 👇 Вот это вставь прямо сюда:
-	if msg.ReplyToMessage != nil && strings.Contains(msg.ReplyToMessage.Text, "укажи свой email") {
-		email := strings.TrimSpace(msg.Text)
-		if !strings.Contains(email, "@") {
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Это не похоже на email, попробуй ещё раз."))
-			return
-		}
-
-		err := repository.UpdateUserContact(userID, email, "")
-		if err != nil {
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось сохранить email."))
-			return
-		}
+	if text == "" {
+		text = msg.Caption
+	}
 
-		bot.Send(tgbotapi.NewMessage(chatID, "✅ Email сохранён! Теперь можешь выбрать пакет."))
-		showBuyOptions(bot, chatID)
-		return
+	var fileIDs []string
+	if fileID != "" {
+		fileIDs = []string{fileID}
 	}
+	handlePrompt(r.bot, r.chatID, r.from.ID, text, fileIDs)
+}
 
-	switch text {
-	case "/start":
-		msg := tgbotapi.NewMessage(chatID, welcomeMessage)
-		msg.ParseMode = "Markdown"
-		bot.Send(msg)
-		return
+// answerPreCheckout подтверждает платёж перед списанием
+func answerPreCheckout(r *request) {
+	r.bot.Request(tgbotapi.PreCheckoutConfig{
+		PreCheckoutQueryID: r.update.PreCheckoutQuery.ID,
+		OK:                 true,
+	})
+}
 
-	case "/help":
-		msg := tgbotapi.NewMessage(chatID, helpMessage)
-		msg.ParseMode = "Markdown"
-		bot.Send(msg)
-		return
+// handlePrompt проверяет промт и картинки и просит подтвердить генерацию.
+// fileIDs — до двух картинок: первый кадр и конечный кадр для интерполяции.
+func handlePrompt(bot *tgbotapi.BotAPI, chatID, userID int64, text string, fileIDs []string) {
+	model := userModel(userID)
 
-	case "/buy":
-		showBuyOptions(bot, chatID)
+	_, params, err := prompt.Parse(text)
+	if err == nil {
+		err = checkModelSupport(model, params, len(fileIDs))
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
 		return
+	}
 
-	case "/balance":
-		balance, err := repository.GetBalance(userID)
+	images := make([]string, 2)
+	for i, fileID := range fileIDs {
+		img, err := prepareImage(bot, fileID, params)
 		if err != nil {
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при получении баланса"))
+			logger.LogError("image", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			bot.Send(tgbotapi.NewMessage(chatID, imageErrorMessage(err)))
 			return
 		}
-		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("💰 У тебя %d кредитов.", balance)))
-		return
+		images[i] = encodeImage(img)
+		label := "Первый кадр"
+		if i == 1 {
+			label = "Последний кадр"
+		}
+		sendImagePreview(bot, chatID, img, params.AspectRatio, label)
 	}
 
-	go func() {
-		if err := repository.EnsureUser(userID, username); err != nil {
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка: "+err.Error()))
-			return
-		}
+	if err := cache.StorePromptRequest(userID, text, images[0], images[1]); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при сохранении запроса"))
+		return
+	}
 
-		imageBase64 := ""
-		if msg.Photo != nil && len(msg.Photo) > 0 {
-			photo := msg.Photo[len(msg.Photo)-1]
-			file, err := bot.GetFile(tgbotapi.FileConfig{FileID: photo.FileID})
-			if err == nil {
-				url := file.Link(bot.Token)
-				imageBase64, err = utils.DownloadAndEncodeImage(url)
-				if err != nil {
-					logger.LogError("image", map[string]interface{}{
-						"user_id": userID,
-						"error":   err.Error(),
-					})
-				}
-			}
-		}
+	summary, markup, err := confirmationView(userID, text, images, nil)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, summary)
+	msg.ReplyMarkup = markup
+	bot.Send(msg)
+}
 
-		if text == "" {
-			text = msg.Caption
-		}
+// handleConfirmCallback запускает генерацию по сохранённому запросу
+func handleConfirmCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	userID := cb.From.ID
 
-		if err := cache.StorePromptRequest(userID, text, imageBase64); err != nil {
-			logger.LogError("redis_store", map[string]interface{}{
-				"user_id": userID,
-				"error":   err.Error(),
-			})
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при сохранении запроса"))
-			return
-		}
+	preset, err := selectedPreset(userID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Выбранный стиль больше недоступен — выбери другой или подтверди без стиля"))
+		cache.SetPromptPreset(userID, 0)
+		return
+	}
+	// запрос забирается атомарно: повторное нажатие или «Прислать готовое» его уже не получат
+	text, imageBase64, lastFrameBase64, err := cache.TakePromptData(userID)
+	if err != nil || text == "" {
+		bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Не удалось получить данные запроса"))
+		return
+	}
 
-		confirmBtn := tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить генерацию", fmt.Sprintf("confirm_%d", userID))
-		msg := tgbotapi.NewMessage(chatID, "🔄 Проверь промт и нажми кнопку, чтобы подтвердить генерацию:")
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(confirmBtn))
-		bot.Send(msg)
-	}()
+	startGeneration(bot, cb.Message.Chat.ID, userID, text, imageBase64, lastFrameBase64, 0, preset)
 }
 
-func handleCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
-	data := cb.Data
-
-	if strings.HasPrefix(data, "confirm_") {
-		userIDStr := strings.TrimPrefix(data, "confirm_")
-		userID, _ := strconv.ParseInt(userIDStr, 10, 64)
-
-		go func() {
-			prompt, imageBase64, err := cache.GetPromptData(userID)
-			if err != nil || prompt == "" {
-				bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Не удалось получить данные запроса"))
-				return
-			}
-
-			balance, err := repository.GetBalance(userID)
-			if err != nil {
-				bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Не удалось получить баланс"))
-				return
-			}
-
-			if balance < 150 {
-				bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "😢 Недостаточно кредитов. Пополни баланс через /buy"))
-				return
-			}
-
-			bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, fmt.Sprintf("🎬 Генерирую видео (150 кр.)… У тебя %d кр. на данный момент.", balance)))
-
-			videoPath, err := generator.GenerateVideo(prompt, userID, imageBase64)
-			if err != nil {
-				bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "❌ Не удалось сгенерировать видео: "+err.Error()))
-				repository.LogAction(userID, "generation_failed", prompt, false, "")
-				cache.ClearPrompt(userID)
-				return
-			}
-
-			if err := repository.SubtractCredits(userID, 150); err != nil {
-				bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Ошибка при списании кредитов"))
-				return
-			}
-
-			video := tgbotapi.NewVideo(cb.Message.Chat.ID, tgbotapi.FilePath(videoPath))
-			video.Caption = "Вот твоё видео!"
-			bot.Send(video)
-
-			newBalance, _ := repository.GetBalance(userID)
-			bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, fmt.Sprintf("✅ Успешно! Остаток: %d кр.", newBalance)))
-
-			cache.ClearPrompt(userID)
-		}()
+func handleFavoriteCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	logID, _ := strconv.ParseInt(strings.TrimPrefix(cb.Data, "fav_"), 10, 64)
+	ok, err := repository.SetFavorite(cb.From.ID, logID)
+	if err != nil || !ok {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Не удалось сохранить выбор"))
 		return
 	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, "⭐ Вариант добавлен в избранное"))
+}
 
-	// обработка покупки
+func handleBuyCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	bot.Request(tgbotapi.NewCallback(cb.ID, ""))
+	sendInvoice(bot, cb.Message.Chat.ID, cb.From.ID, cb.Data)
+}
+
+// sendInvoice отправляет в chatID инвойс на пакет кредитов (buy_200, buy_500, buy_1200) для покупателя userID;
+// без email сначала просит его и продолжает покупку, когда email придёт
+func sendInvoice(bot *tgbotapi.BotAPI, chatID, userID int64, pack string) {
 	var credits, price int
 	var label, startParam string
 
-	switch data {
+	switch pack {
 	case "buy_200":
 		credits, price, label, startParam = 200, 45000, "200 кредитов", "buy_200"
 	case "buy_500":
@@ -232,16 +277,14 @@ func handleCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
 		return
 	}
 
-	user, err := repository.GetUserByID(cb.Message.Chat.ID)
+	user, err := repository.GetUserByID(userID)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Не удалось получить данные пользователя."))
-
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось получить данные пользователя."))
+		return
 	}
 
 	if user.Email == "" {
-		msg := tgbotapi.NewMessage(cb.Message.Chat.ID, "📧 Пожалуйста, укажи свой email, чтобы мы могли оформить чек.")
-		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
-		bot.Send(msg)
+		askEmail(bot, chatID, userID, "📧 Пожалуйста, укажи свой email, чтобы мы могли оформить чек.", pack)
 		return
 	}
 
@@ -277,14 +320,14 @@ func handleCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
 	}
 	providerDataJSON, err := json.Marshal(providerData)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "⚠️ Ошибка при формировании чека"))
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при формировании чека"))
 		return
 	}
 
 	// Инвойс
 	// Формируем инвойс
 	invoice := tgbotapi.InvoiceConfig{
-		BaseChat:       tgbotapi.BaseChat{ChatID: cb.Message.Chat.ID},
+		BaseChat:       tgbotapi.BaseChat{ChatID: chatID},
 		Title:          "Покупка кредитов",
 		Description:    fmt.Sprintf("Пакет: %s", label),
 		Payload:        fmt.Sprintf("credits_%d", credits),
@@ -301,21 +344,19 @@ func handleCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
 	// Отправка инвойса
 	if _, err := bot.Send(invoice); err != nil {
 		logger.LogError("send_invoice", map[string]interface{}{
-			"user_id": cb.Message.Chat.ID,
+			"user_id": userID,
 			"error":   err.Error(),
 			"json":    string(providerDataJSON),
 		})
-		bot.Send(tgbotapi.NewMessage(cb.Message.Chat.ID, "❌ Ошибка при отправке инвойса: "+err.Error()))
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при отправке инвойса: "+err.Error()))
 	}
 }
 
-func showBuyOptions(bot *tgbotapi.BotAPI, chatID int64) {
-	userID := chatID // если ты не используешь групповой чат
-
+func showBuyOptions(bot *tgbotapi.BotAPI, chatID, userID int64) {
 	hasEmail, err := repository.HasEmail(userID)
 	if err != nil {
 		logger.LogError("send_invoice", map[string]interface{}{
-			"user_id": chatID,
+			"user_id": userID,
 			"error":   err.Error(),
 			"json":    hasEmail,
 		})
@@ -323,11 +364,7 @@ func showBuyOptions(bot *tgbotapi.BotAPI, chatID int64) {
 	}
 
 	if !hasEmail {
-		// Спросим email
-		msg := tgbotapi.NewMessage(chatID, "📧 Пожалуйста, укажи свой email для получения чека:")
-		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
-		bot.Send(msg)
-		// При следующем сообщении будет reply_to_message — нужно отследить
+		askEmail(bot, chatID, userID, "📧 Пожалуйста, укажи свой email для получения чека:", "")
 		return
 	}
 
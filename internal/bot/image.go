@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/imageproc"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// imageFileID — картинка из сообщения: сжатое фото или файл-документ без сжатия
+func imageFileID(msg *tgbotapi.Message) (string, error) {
+	if len(msg.Photo) > 0 {
+		return msg.Photo[len(msg.Photo)-1].FileID, nil
+	}
+	if doc := msg.Document; doc != nil {
+		if !strings.HasPrefix(doc.MimeType, "image/") {
+			return "", nil
+		}
+		if doc.FileSize > imageproc.MaxInputBytes {
+			return "", fmt.Errorf("картинка больше %d МБ", imageproc.MaxInputBytes>>20)
+		}
+		return doc.FileID, nil
+	}
+	return "", nil
+}
+
+// prepareImage скачивает картинку из Telegram и приводит её к формату кадра из params
+func prepareImage(bot *tgbotapi.BotAPI, fileID string, params prompt.GenerationParams) (*imageproc.Result, error) {
+	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+	data, err := utils.DownloadImage(file.Link(bot.Token), imageproc.MaxInputBytes)
+	if err != nil {
+		return nil, err
+	}
+	return imageproc.Normalize(data, params.AspectRatio, params.Fit)
+}
+
+// sendImagePreview показывает ровно тот кадр, который уйдёт в генерацию
+func sendImagePreview(bot *tgbotapi.BotAPI, chatID int64, img *imageproc.Result, aspect, label string) {
+	var how string
+	switch img.Fit {
+	case imageproc.FitCrop:
+		how = "края обрезаны (#pad — вписать целиком)"
+	case imageproc.FitPad:
+		how = "добавлены поля (#crop — обрезать края)"
+	default:
+		how = "без изменений"
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "frame.jpg", Bytes: img.Data})
+	photo.Caption = fmt.Sprintf("🖼 %s: %d×%d, %s, %s", label, img.Width, img.Height, aspect, how)
+	bot.Send(photo)
+}
+
+// imageErrorMessage — ответ пользователю, если картинку не удалось подготовить
+func imageErrorMessage(err error) string {
+	if errors.Is(err, imageproc.ErrUnsupported) {
+		return "⚠️ Не получилось прочитать картинку: " + err.Error()
+	}
+	return "⚠️ Не удалось обработать картинку: " + err.Error()
+}
+
+func encodeImage(img *imageproc.Result) string {
+	return base64.StdEncoding.EncodeToString(img.Data)
+}
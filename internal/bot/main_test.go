@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+)
+
+// TestMain запускает тесты во временном каталоге: туда пишутся логи
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bot-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	logger.Init()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько сообщений и нажатий кнопок в минуту принимается от одного пользователя
+var rateLimitPerMinute = utils.GetEnvInt("RATE_LIMIT_PER_MINUTE", 30)
+
+// recoverPanics не даёт панике в обработчике уронить бота и сообщает пользователю об ошибке
+func recoverPanics(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.LogError("panic", map[string]interface{}{
+					"user_id": r.from.ID,
+					"command": r.command,
+					"error":   fmt.Sprint(p),
+					"stack":   string(debug.Stack()),
+				})
+				r.reply("⚠️ Что-то пошло не так, попробуй ещё раз")
+			}
+		}()
+		next(r)
+	}
+}
+
+// logUpdates пишет обновление в лог до обработки
+func logUpdates(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		logger.LogUpdate(r.update)
+		switch {
+		case r.callback() != nil:
+			logger.LogCallback(r.callback())
+		case r.message() != nil && r.message().SuccessfulPayment == nil:
+			logger.LogMessage(r.message())
+		}
+		next(r)
+	}
+}
+
+type rateWindow struct {
+	start  time.Time
+	count  int
+	warned bool
+}
+
+var (
+	rateMu      sync.Mutex
+	rateWindows = map[int64]*rateWindow{}
+)
+
+// rateLimit ограничивает частоту запросов пользователя окном в минуту.
+// Платежи и операторы не ограничиваются; о превышении пользователь узнаёт один раз за окно.
+func rateLimit(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if r.update.PreCheckoutQuery != nil || (r.message() != nil && r.message().SuccessfulPayment != nil) || isAdmin(r.from.ID) {
+			next(r)
+			return
+		}
+
+		allowed, warn := takeRateToken(r.from.ID, time.Now())
+		if allowed {
+			next(r)
+			return
+		}
+		if warn {
+			logger.LogInfo("rate_limited", map[string]interface{}{
+				"user_id": r.from.ID,
+				"limit":   rateLimitPerMinute,
+			})
+			r.reply("⏳ Слишком много запросов — подожди минуту")
+		} else if r.callback() != nil {
+			// на нажатие кнопки нужно ответить, иначе у пользователя будут «часики»
+			r.bot.Request(tgbotapi.NewCallback(r.callback().ID, ""))
+		}
+	}
+}
+
+// takeRateToken учитывает запрос; warn — превышение в этом окне первое
+func takeRateToken(userID int64, now time.Time) (allowed, warn bool) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+
+	w, ok := rateWindows[userID]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		// заодно убираем окна неактивных пользователей, чтобы карта не росла
+		if !ok && len(rateWindows) > 10000 {
+			for id, old := range rateWindows {
+				if now.Sub(old.start) >= time.Minute {
+					delete(rateWindows, id)
+				}
+			}
+		}
+		w = &rateWindow{start: now}
+		rateWindows[userID] = w
+	}
+	w.count++
+	if w.count <= rateLimitPerMinute {
+		return true, false
+	}
+	warn = !w.warned
+	w.warned = true
+	return false, warn
+}
+
+// registerUser заводит пользователя в users при первом обращении
+func registerUser(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if err := repository.EnsureUser(r.from.ID, r.from.UserName); err != nil {
+			logger.LogError("user_register", map[string]interface{}{
+				"user_id": r.from.ID,
+				"error":   err.Error(),
+			})
+			r.reply("⚠️ Ошибка: " + err.Error())
+			return
+		}
+		next(r)
+	}
+}
+
+// rejectBlocked не пускает заблокированных пользователей (users.is_blocked).
+// Уже прошедший платёж обрабатывается всегда, а новый платёж заблокированному не подтверждается.
+func rejectBlocked(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if r.message() != nil && r.message().SuccessfulPayment != nil {
+			next(r)
+			return
+		}
+
+		blocked, err := repository.IsUserBlocked(r.from.ID)
+		if err != nil {
+			logger.LogError("user_blocked_check", map[string]interface{}{
+				"user_id": r.from.ID,
+				"error":   err.Error(),
+			})
+		}
+		if !blocked {
+			next(r)
+			return
+		}
+
+		logger.LogInfo("user_blocked", map[string]interface{}{
+			"user_id": r.from.ID,
+		})
+		if q := r.update.PreCheckoutQuery; q != nil {
+			r.bot.Request(tgbotapi.PreCheckoutConfig{
+				PreCheckoutQueryID: q.ID,
+				OK:                 false,
+				ErrorMessage:       "Аккаунт заблокирован",
+			})
+			return
+		}
+		r.reply("⛔ Доступ к боту ограничен")
+	}
+}
+
+// adminOnly пропускает только операторов из ADMIN_IDS
+func adminOnly(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if !isAdmin(r.from.ID) {
+			r.reply("⛔ Команда доступна только операторам")
+			return
+		}
+		next(r)
+	}
+}
+
+// ownerOnly — кнопка с id пользователя в данных (confirm_<id>) срабатывает только у него:
+// в группе сообщение с кнопкой видят все, и чужое нажатие не должно запускать генерацию
+func ownerOnly(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		data := r.callback().Data
+		owner, err := strconv.ParseInt(data[strings.LastIndex(data, "_")+1:], 10, 64)
+		if err != nil || owner != r.from.ID {
+			r.reply("⛔ Эта кнопка не для тебя")
+			return
+		}
+		next(r)
+	}
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestOwnerOnlyRejectsOtherUsers(t *testing.T) {
+	bot, tg := newTestBot(t)
+	var confirmed []int64
+	rt := newRouter()
+	rt.callback("confirm_", func(r *request) { confirmed = append(confirmed, r.from.ID) }, ownerOnly)
+
+	press := func(from int64, data string) {
+		rt.dispatch(bot, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "cb",
+			From:    &tgbotapi.User{ID: from},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100}},
+			Data:    data,
+		}})
+	}
+	press(2, "confirm_1")
+	press(1, "confirm_x")
+	press(1, "confirm_1")
+
+	if len(confirmed) != 1 || confirmed[0] != 1 {
+		t.Errorf("кнопка сработала у %v, ожидалось только у владельца 1", confirmed)
+	}
+	if answers := tg.texts("answerCallbackQuery"); len(answers) != 2 {
+		t.Errorf("отказов: %d, ожидалось 2", len(answers))
+	}
+}
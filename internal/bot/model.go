@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// userModel — модель, выбранная пользователем в /model, или модель по умолчанию
+func userModel(userID int64) config.ModelConfig {
+	modelID, err := repository.GetUserModel(userID)
+	if err != nil {
+		logger.LogError("user_model", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+	if m, ok := config.Lookup(modelID); ok {
+		return m
+	}
+	return config.DefaultModel()
+}
+
+func modelKeyboard(selectedID string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range config.Models() {
+		label := fmt.Sprintf("%s — %d кр.", m.DisplayName(), m.Price)
+		if m.ID == selectedID {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "model_"+m.ID),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// describeModel — возможности модели одной строкой
+func describeModel(m config.ModelConfig) string {
+	features := []string{
+		"форматы " + strings.Join(m.AspectRatios, ", "),
+		"до " + fmt.Sprintf("%d с", m.DefaultDuration()),
+		strings.Join(m.Resolutions, "/"),
+	}
+	if m.Audio {
+		features = append(features, "со звуком")
+	}
+	if m.ImageInput {
+		features = append(features, "принимает картинку")
+	}
+	if m.LastFrame {
+		features = append(features, "интерполяция между двумя кадрами")
+	}
+	return fmt.Sprintf("%s: %s · %d кр. за видео", m.DisplayName(), strings.Join(features, " · "), m.Price)
+}
+
+func showModelOptions(bot *tgbotapi.BotAPI, chatID, userID int64) {
+	current := userModel(userID)
+
+	lines := []string{"🤖 Выбери модель по умолчанию:", ""}
+	for _, m := range config.Models() {
+		lines = append(lines, "• "+describeModel(m))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	msg.ReplyMarkup = modelKeyboard(current.ID)
+	bot.Send(msg)
+}
+
+func handleModelCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	modelID := strings.TrimPrefix(cb.Data, "model_")
+	m, ok := config.Lookup(modelID)
+	if !ok {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Эта модель больше недоступна"))
+		return
+	}
+
+	if err := repository.SetUserModel(cb.From.ID, m.ID); err != nil {
+		logger.LogError("user_model", map[string]interface{}{
+			"user_id": cb.From.ID,
+			"model":   m.ID,
+			"error":   err.Error(),
+		})
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Не удалось сохранить выбор"))
+		return
+	}
+
+	bot.Request(tgbotapi.NewCallback(cb.ID, "✅ Модель: "+m.DisplayName()))
+	bot.Request(tgbotapi.NewEditMessageReplyMarkup(cb.Message.Chat.ID, cb.Message.MessageID, modelKeyboard(m.ID)))
+}
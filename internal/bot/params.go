@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+)
+
+// describeParams — параметры генерации для сообщения с подтверждением
+func describeParams(model config.ModelConfig, p prompt.GenerationParams) string {
+	duration := p.DurationSeconds
+	if duration == 0 {
+		duration = model.DefaultDuration()
+	}
+
+	parts := []string{
+		"формат " + p.AspectRatio,
+		fmt.Sprintf("%d с", duration),
+	}
+	if p.Resolution != "" {
+		parts = append(parts, p.Resolution)
+	}
+	if !p.Audio() || !model.Audio {
+		parts = append(parts, "без звука")
+	}
+	if p.Seed != nil {
+		parts = append(parts, fmt.Sprintf("seed %d", *p.Seed))
+	}
+	if p.Samples() > 1 {
+		parts = append(parts, fmt.Sprintf("вариантов: %d", p.Samples()))
+	}
+	if p.Fit == "pad" {
+		parts = append(parts, "картинка с полями")
+	}
+	if p.NegativePrompt != "" {
+		parts = append(parts, "избегать: "+p.NegativePrompt)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// checkModelSupport сверяет параметры с возможностями модели из реестра.
+// images — сколько картинок пришло: 1 — первый кадр, 2 — первый и последний.
+func checkModelSupport(model config.ModelConfig, p prompt.GenerationParams, images int) error {
+	name := model.DisplayName()
+	var problems []string
+
+	if !model.SupportsAspectRatio(p.AspectRatio) {
+		problems = append(problems, fmt.Sprintf("%s не поддерживает формат %s (доступно: %s)",
+			name, p.AspectRatio, strings.Join(model.AspectRatios, ", ")))
+	}
+	if p.DurationSeconds != 0 && !model.SupportsDuration(p.DurationSeconds) {
+		durations := make([]string, 0, len(model.Durations))
+		for _, d := range model.Durations {
+			durations = append(durations, fmt.Sprintf("%ds", d))
+		}
+		problems = append(problems, fmt.Sprintf("%s не поддерживает длительность %d с (доступно: %s)",
+			name, p.DurationSeconds, strings.Join(durations, ", ")))
+	}
+	if p.Resolution != "" && !model.SupportsResolution(p.Resolution) {
+		problems = append(problems, fmt.Sprintf("%s не поддерживает %s (доступно: %s)",
+			name, p.Resolution, strings.Join(model.Resolutions, ", ")))
+	}
+	if p.GenerateAudio != nil && *p.GenerateAudio && !model.Audio {
+		problems = append(problems, fmt.Sprintf("%s не генерирует звук", name))
+	}
+	if images > 0 && !model.ImageInput {
+		problems = append(problems, fmt.Sprintf("%s не принимает картинку — отправь только текст или выбери другую модель в /model", name))
+	}
+	if images > 1 && model.ImageInput && !model.LastFrame {
+		problems = append(problems, fmt.Sprintf("%s не умеет интерполяцию между двумя кадрами — отправь одну картинку или выбери в /model модель, которая умеет: %s",
+			name, strings.Join(lastFrameModels(), ", ")))
+	}
+
+	if len(problems) > 0 {
+		return &prompt.ParseError{Problems: problems}
+	}
+	return nil
+}
+
+// paramsErrorMessage — ответ пользователю на ошибки в тегах
+func paramsErrorMessage(err error) string {
+	var parseErr *prompt.ParseError
+	if errors.As(err, &parseErr) {
+		return "⚠️ Не удалось разобрать параметры:\n• " + strings.Join(parseErr.Problems, "\n• ") +
+			"\n\nИсправь промт и отправь его ещё раз."
+	}
+	return "⚠️ Ошибка в параметрах: " + err.Error()
+}
+
+// lastFrameModels — модели из реестра, которые принимают конечный кадр
+func lastFrameModels() []string {
+	var names []string
+	for _, m := range config.Models() {
+		if m.LastFrame {
+			names = append(names, m.DisplayName())
+		}
+	}
+	if len(names) == 0 {
+		return []string{"таких сейчас нет"}
+	}
+	return names
+}
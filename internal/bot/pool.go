@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/metrics"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// не больше стольких генераций одновременно ходят в Vertex AI, остальные ждут в очереди
+var maxConcurrent = utils.GetEnvInt("MAX_CONCURRENT_GENERATIONS", 4)
+
+// queuedJob — задача в очереди; позиция показывается в сообщении о ходе генерации
+type queuedJob struct {
+	job        *models.GenerationJob
+	enqueuedAt time.Time
+	position   int  // последняя показанная позиция, 0 — не показывалась
+	started    bool // задачу уже взял воркер
+	cancelled  bool // пользователь отменил задачу, пока она ждала
+}
+
+// activeJob — задача, которую выполняет воркер; cancel останавливает её запуск и опрос
+type activeJob struct {
+	job    *models.GenerationJob
+	cancel context.CancelFunc
+}
+
+// jobPool — FIFO-очередь задач и фиксированное число воркеров
+type jobPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*queuedJob
+	running int
+	active  map[int64]*activeJob
+
+	// статистика ожидания для метрик и /queue
+	dequeued  int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+
+	notify chan struct{}
+	bot    *tgbotapi.BotAPI
+}
+
+var pool = newJobPool()
+
+func newJobPool() *jobPool {
+	p := &jobPool{notify: make(chan struct{}, 1), active: map[int64]*activeJob{}}
+	p.cond = sync.NewCond(&p.mu)
+	metrics.Func("generation_queue", func() interface{} { return p.stats() })
+	return p
+}
+
+// QueueStats — срез состояния очереди
+type QueueStats struct {
+	Workers            int     `json:"workers"`
+	Running            int     `json:"running"`
+	Depth              int     `json:"depth"`
+	OldestWaitSeconds  float64 `json:"oldest_wait_seconds"`
+	Dequeued           int64   `json:"dequeued"`
+	AverageWaitSeconds float64 `json:"average_wait_seconds"`
+	MaxWaitSeconds     float64 `json:"max_wait_seconds"`
+}
+
+func (p *jobPool) stats() QueueStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := QueueStats{
+		Workers:        maxConcurrent,
+		Running:        p.running,
+		Depth:          len(p.queue),
+		Dequeued:       p.dequeued,
+		MaxWaitSeconds: p.waitMax.Seconds(),
+	}
+	if len(p.queue) > 0 {
+		s.OldestWaitSeconds = time.Since(p.queue[0].enqueuedAt).Seconds()
+	}
+	if p.dequeued > 0 {
+		s.AverageWaitSeconds = p.waitTotal.Seconds() / float64(p.dequeued)
+	}
+	return s
+}
+
+// StartWorkers запускает воркеры генерации и рассыльщик позиций в очереди.
+// Вызывается один раз до ResumeJobs.
+func StartWorkers(bot *tgbotapi.BotAPI) {
+	pool.bot = bot
+	for i := 0; i < maxConcurrent; i++ {
+		go pool.work()
+	}
+	go pool.notifyPositions()
+}
+
+// enqueueJob ставит задачу в конец очереди; пользователь узнает позицию, если все воркеры заняты
+func enqueueJob(job *models.GenerationJob) {
+	pool.mu.Lock()
+	pool.queue = append(pool.queue, &queuedJob{job: job, enqueuedAt: time.Now()})
+	pool.mu.Unlock()
+
+	pool.cond.Signal()
+	pool.wake()
+}
+
+func (p *jobPool) work() {
+	for {
+		item, ctx, cancel := p.next()
+		wait := time.Since(item.enqueuedAt)
+		logger.LogInfo("job_dequeued", map[string]interface{}{
+			"job_id":       item.job.ID,
+			"user_id":      item.job.UserID,
+			"wait_seconds": wait.Seconds(),
+		})
+
+		runJob(ctx, p.bot, item.job)
+
+		cancel()
+
+		p.mu.Lock()
+		p.running--
+		delete(p.active, item.job.ID)
+		p.mu.Unlock()
+	}
+}
+
+// next ждёт задачу из головы очереди и сообщает об этом её владельцу
+func (p *jobPool) next() (*queuedJob, context.Context, context.CancelFunc) {
+	p.mu.Lock()
+	for len(p.queue) == 0 {
+		p.cond.Wait()
+	}
+	item := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+	p.running++
+	item.started = true
+	ctx, cancel := context.WithCancel(context.Background())
+	p.active[item.job.ID] = &activeJob{job: item.job, cancel: cancel}
+
+	wait := time.Since(item.enqueuedAt)
+	p.dequeued++
+	p.waitTotal += wait
+	if wait > p.waitMax {
+		p.waitMax = wait
+	}
+	shown := item.position != 0
+	p.mu.Unlock()
+
+	if shown {
+		p.startedMessage(item.job)
+	}
+	p.wake()
+	return item, ctx, cancel
+}
+
+// cancelJob убирает задачу пользователя из очереди или останавливает её выполнение.
+// queued — задача ещё ждала в очереди и в Vertex AI не отправлялась; в этом случае
+// завершать её должен вызывающий, иначе это сделает runJob по отменённому контексту.
+func (p *jobPool) cancelJob(jobID, userID int64) (job *models.GenerationJob, queued bool, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, item := range p.queue {
+		if item.job.ID != jobID {
+			continue
+		}
+		if item.job.UserID != userID {
+			return nil, false, false
+		}
+		item.cancelled = true
+		p.queue = append(p.queue[:i], p.queue[i+1:]...)
+		p.wake()
+		return item.job, true, true
+	}
+
+	if a, found := p.active[jobID]; found && a.job.UserID == userID {
+		a.cancel()
+		delete(p.active, jobID)
+		return a.job, false, true
+	}
+	return nil, false, false
+}
+
+// detach делает задачу неотменяемой: видео уже получены, осталось их доставить.
+// false — пользователь успел отменить задачу раньше (cancelJob под тем же мьютексом), доставлять её нельзя.
+func (p *jobPool) detach(jobID int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.active[jobID]; !ok {
+		return false
+	}
+	delete(p.active, jobID)
+	return true
+}
+
+// wake просит рассыльщика обновить позиции; несколько сдвигов подряд схлопываются в один проход
+func (p *jobPool) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// notifyPositions показывает ждущим задачам их позицию в очереди.
+// Работает в одной горутине, поэтому position меняется только здесь.
+func (p *jobPool) notifyPositions() {
+	for range p.notify {
+		type update struct {
+			item     *queuedJob
+			position int
+		}
+		var updates []update
+
+		p.mu.Lock()
+		busy := p.running >= maxConcurrent
+		for i, item := range p.queue {
+			// у сцен раскадровки очередь видна в общем сообщении о прогрессе
+			if item.job.ProgressMessageID == 0 || item.position == i+1 {
+				continue
+			}
+			// пока есть свободный воркер, задача вот-вот начнётся — не спамим
+			if item.position == 0 && !busy {
+				continue
+			}
+			updates = append(updates, update{item, i + 1})
+		}
+		p.mu.Unlock()
+
+		for _, u := range updates {
+			p.showPosition(u.item, u.position)
+		}
+	}
+}
+
+func (p *jobPool) showPosition(item *queuedJob, position int) {
+	p.mu.Lock()
+	cancelled := item.cancelled
+	p.mu.Unlock()
+	if cancelled {
+		return
+	}
+
+	editJobStatus(p.bot, item.job, fmt.Sprintf("⏳ Все генераторы заняты. Ты в очереди: %d — генерация начнётся автоматически.", position))
+
+	p.mu.Lock()
+	item.position = position
+	started := item.started
+	p.mu.Unlock()
+
+	// воркер взял задачу, пока сообщение редактировалось
+	if started {
+		p.startedMessage(item.job)
+	}
+}
+
+func (p *jobPool) startedMessage(job *models.GenerationJob) {
+	editJobStatus(p.bot, job, "🚀 Очередь дошла — запускаю генерацию…")
+}
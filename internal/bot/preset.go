@@ -0,0 +1,323 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// столько кнопок пресетов в одном ряду под сообщением с подтверждением
+const presetsPerRow = 3
+
+var presetNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+const presetAddUsage = "Формат: /preset_add имя | Название | префикс | суффикс | формат | негативный промт | модель\n" +
+	"Обязательны имя и название, остальные поля можно оставить пустыми или опустить.\n" +
+	"Пример: /preset_add cinematic | 🎬 Кино | cinematic shot, 35mm film | golden hour, shallow depth of field | 16:9 | blurry, text"
+
+// resolvePrompt разбирает промт с учётом пресета: префикс и суффикс оборачивают промт, формат и негативный
+// промт пресета действуют, только если пользователь не задал их тегами, модель пресета заменяет модель пользователя.
+// Если картинки уже вписаны в кадр (hasImage), формат пресета не применяется.
+func resolvePrompt(userID int64, text string, preset *models.Preset, hasImage bool) (string, prompt.GenerationParams, config.ModelConfig, error) {
+	model := userModel(userID)
+	if preset == nil {
+		cleanPrompt, params, err := prompt.Parse(text)
+		return cleanPrompt, params, model, err
+	}
+
+	defaults := prompt.GenerationParams{NegativePrompt: preset.NegativePrompt}
+	if !hasImage {
+		defaults.AspectRatio = preset.AspectRatio
+	}
+	cleanPrompt, params, err := prompt.ParseWithDefaults(text, defaults)
+	if err != nil {
+		return "", params, model, err
+	}
+	if m, ok := config.Lookup(preset.ModelID); ok {
+		model = m
+	}
+	return preset.Apply(cleanPrompt), params, model, nil
+}
+
+// confirmationView собирает текст и кнопки сообщения с подтверждением генерации.
+// images — первый и конечный кадр в base64 (пустые строки, если картинок нет).
+func confirmationView(userID int64, text string, images []string, preset *models.Preset) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	imageCount := 0
+	for _, img := range images {
+		if img != "" {
+			imageCount++
+		}
+	}
+
+	cleanPrompt, params, model, err := resolvePrompt(userID, text, preset, imageCount > 0)
+	if err == nil {
+		err = checkModelSupport(model, params, imageCount)
+	}
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	summary := "🔄 Проверь промт и нажми кнопку, чтобы подтвердить генерацию:\n\n"
+	if preset != nil {
+		summary += "🎨 Стиль: " + preset.Title + "\n"
+	}
+	summary += "📝 Промт: " + cleanPrompt + "\n" +
+		"⚙️ Параметры: " + describeParams(model, params) + "\n"
+	if imageCount == 2 {
+		summary += "🎞️ Интерполяция: видео пройдёт от первого кадра к последнему\n"
+	}
+	summary += fmt.Sprintf("🤖 Модель: %s · %d кр.", model.DisplayName(), model.Price*params.Samples())
+
+	confirmBtn := tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить генерацию", fmt.Sprintf("confirm_%d", userID))
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(confirmBtn, promptEditButton(userID))}
+	// такой же запрос с #seed уже генерировался — можно прислать готовое видео вместо новой генерации
+	if cached := findCachedResult(userID, model.ID, cleanPrompt, params, images[0], images[1]); cached != nil {
+		summary += "\n\n♻️ Точно такое видео уже генерировалось " + cached.CreatedAt.Format("02.01.2006 15:04")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(dedupButton(cached)))
+	}
+	rows = append(rows, presetRows(preset)...)
+
+	return summary, tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// presetRows — кнопки активных пресетов; выбранный отмечен галочкой, повторное нажатие снимает его
+func presetRows(selected *models.Preset) [][]tgbotapi.InlineKeyboardButton {
+	presets, err := repository.ListPresets(true)
+	if err != nil {
+		logger.LogError("presets", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, p := range presets {
+		label := p.Title
+		if selected != nil && p.ID == selected.ID {
+			label = "✅ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("preset_%d", p.ID)))
+		if len(row) == presetsPerRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// selectedPreset — пресет, выбранный для сохранённого запроса; nil — без пресета
+func selectedPreset(userID int64) (*models.Preset, error) {
+	presetID := cache.GetPromptPreset(userID)
+	if presetID == 0 {
+		return nil, nil
+	}
+	p, err := repository.GetPreset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Active {
+		return nil, errors.New("пресет отключён")
+	}
+	return p, nil
+}
+
+// handlePresetCallback выбирает пресет для запроса и перерисовывает сообщение с подтверждением
+func handlePresetCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	presetID, _ := strconv.ParseInt(strings.TrimPrefix(cb.Data, "preset_"), 10, 64)
+	userID := cb.From.ID
+
+	text, imageBase64, lastFrameBase64, err := cache.GetPromptData(userID)
+	if err != nil || text == "" {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Запрос устарел — отправь промт ещё раз"))
+		return
+	}
+
+	var preset *models.Preset
+	if presetID != cache.GetPromptPreset(userID) {
+		preset, err = repository.GetPreset(presetID)
+		if err != nil || !preset.Active {
+			bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Этот стиль больше недоступен"))
+			return
+		}
+	}
+
+	summary, markup, err := confirmationView(userID, text, []string{imageBase64, lastFrameBase64}, preset)
+	if err != nil {
+		var parseErr *prompt.ParseError
+		reason := err.Error()
+		if errors.As(err, &parseErr) {
+			reason = parseErr.Problems[0]
+		}
+		bot.Request(tgbotapi.NewCallbackWithAlert(cb.ID, "⚠️ Стиль не подходит к запросу: "+reason))
+		return
+	}
+
+	selected := int64(0)
+	answer := "Стиль снят"
+	if preset != nil {
+		selected = preset.ID
+		answer = "🎨 Стиль: " + preset.Title
+	}
+	if err := cache.SetPromptPreset(userID, selected); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Не удалось сохранить выбор"))
+		return
+	}
+
+	bot.Request(tgbotapi.NewCallback(cb.ID, answer))
+	bot.Send(tgbotapi.NewEditMessageTextAndMarkup(cb.Message.Chat.ID, cb.Message.MessageID, summary, markup))
+}
+
+// recordPresetUsage записывает генерацию с пресетом для аналитики
+func recordPresetUsage(preset *models.Preset, job *models.GenerationJob) {
+	if err := repository.RecordPresetUsage(preset.ID, job.UserID, job.ID); err != nil {
+		logger.LogError("preset_usage", map[string]interface{}{
+			"preset_id": preset.ID,
+			"job_id":    job.ID,
+			"error":     err.Error(),
+		})
+	}
+	logger.LogInfo("preset_used", map[string]interface{}{
+		"preset":  preset.Name,
+		"job_id":  job.ID,
+		"user_id": job.UserID,
+	})
+}
+
+// showPresets — /presets: все пресеты с настройками и статистикой использования
+func showPresets(bot *tgbotapi.BotAPI, chatID int64) {
+	presets, err := repository.ListPresets(false)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось получить пресеты"))
+		return
+	}
+	if len(presets) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "🎨 Пресетов пока нет.\n\n"+presetAddUsage))
+		return
+	}
+	stats, err := repository.PresetUsageStats()
+	if err != nil {
+		logger.LogError("preset_usage", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	lines := []string{"🎨 Пресеты стилей", ""}
+	for _, p := range presets {
+		state := ""
+		if !p.Active {
+			state = " (отключён)"
+		}
+		lines = append(lines, fmt.Sprintf("• %s — %s%s", p.Name, p.Title, state))
+		if p.PromptPrefix != "" || p.PromptSuffix != "" {
+			lines = append(lines, "  промт: "+p.Apply("…"))
+		}
+		var extra []string
+		if p.AspectRatio != "" {
+			extra = append(extra, "формат "+p.AspectRatio)
+		}
+		if p.NegativePrompt != "" {
+			extra = append(extra, "избегать: "+p.NegativePrompt)
+		}
+		if p.ModelID != "" {
+			extra = append(extra, "модель "+p.ModelID)
+		}
+		if len(extra) > 0 {
+			lines = append(lines, "  "+strings.Join(extra, " · "))
+		}
+		s := stats[p.ID]
+		lines = append(lines, fmt.Sprintf("  генераций: %d (за 7 дней: %d), пользователей: %d", s.Uses, s.Recent, s.Users))
+	}
+	lines = append(lines, "", "/preset_add — добавить или изменить, /preset_del имя — отключить")
+	bot.Send(tgbotapi.NewMessage(chatID, strings.Join(lines, "\n")))
+}
+
+// handlePresetAdd — /preset_add имя | Название | префикс | суффикс | формат | негативный промт | модель
+func handlePresetAdd(bot *tgbotapi.BotAPI, chatID int64, args string) {
+	p, err := parsePresetArgs(args)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ "+err.Error()+"\n\n"+presetAddUsage))
+		return
+	}
+	if err := repository.SavePreset(p); err != nil {
+		logger.LogError("presets", map[string]interface{}{
+			"preset": p.Name,
+			"error":  err.Error(),
+		})
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось сохранить пресет"))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Пресет %s сохранён: %s", p.Name, p.Title)))
+}
+
+func parsePresetArgs(args string) (*models.Preset, error) {
+	fields := strings.Split(args, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) > 7 {
+		return nil, errors.New("слишком много полей")
+	}
+	for len(fields) < 7 {
+		fields = append(fields, "")
+	}
+
+	p := &models.Preset{
+		Name:           strings.ToLower(fields[0]),
+		Title:          fields[1],
+		PromptPrefix:   fields[2],
+		PromptSuffix:   fields[3],
+		AspectRatio:    fields[4],
+		NegativePrompt: fields[5],
+		ModelID:        fields[6],
+	}
+	switch {
+	case !presetNameRe.MatchString(p.Name):
+		return nil, errors.New("имя — латиница, цифры, _ и -, до 50 символов")
+	case p.Title == "":
+		return nil, errors.New("нужно название для кнопки")
+	case p.AspectRatio != "" && !prompt.SupportedAspectRatio(p.AspectRatio):
+		return nil, fmt.Errorf("формат %s не поддерживается — 16:9 или 9:16", p.AspectRatio)
+	}
+	if p.ModelID != "" {
+		if _, ok := config.Lookup(p.ModelID); !ok {
+			return nil, fmt.Errorf("модели %s нет в реестре", p.ModelID)
+		}
+	}
+	return p, nil
+}
+
+// handlePresetDel — /preset_del имя
+func handlePresetDel(bot *tgbotapi.BotAPI, chatID int64, name string) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "Формат: /preset_del имя"))
+		return
+	}
+	ok, err := repository.DisablePreset(name)
+	switch {
+	case err != nil:
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось отключить пресет"))
+	case !ok:
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Активного пресета "+name+" нет"))
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, "✅ Пресет "+name+" отключён, статистика сохранена"))
+	}
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/generator"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// ETA считается по стольким последним успешным генерациям модели
+	etaSampleSize = 50
+	// с меньшей историей оценка слишком шумная — берём defaultETA
+	etaMinSamples = 3
+	defaultETA    = 2 * time.Minute
+
+	progressBarWidth = 10
+)
+
+// jobHeader — первая строка сообщения о ходе генерации
+func jobHeader(job *models.GenerationJob) string {
+	name := job.ModelID
+	if m, ok := config.Lookup(job.ModelID); ok {
+		name = m.DisplayName()
+	}
+	if job.ChainID != 0 {
+		return fmt.Sprintf("🎞️ Продолжение видео на %s · %d кр.", name, job.Credits)
+	}
+	return fmt.Sprintf("🎬 Видео на %s · %d кр.", name, job.Credits)
+}
+
+// sendJobStatus отправляет сообщение о ходе генерации и запоминает его в задаче.
+// У сцен раскадровки своё общее сообщение, для них ничего не отправляется.
+func sendJobStatus(bot *tgbotapi.BotAPI, job *models.GenerationJob, status string) {
+	if job.StoryID != 0 {
+		return
+	}
+	msg := tgbotapi.NewMessage(job.ChatID, jobHeader(job)+"\n"+status)
+	if markup := jobStatusMarkup(job); markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	sent, err := bot.Send(msg)
+	if err != nil {
+		return
+	}
+	job.ProgressMessageID = sent.MessageID
+	if err := repository.SetJobProgressMessage(job.ID, sent.MessageID); err != nil {
+		logger.LogError("job_progress", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// editJobStatus заменяет состояние в сообщении о ходе генерации;
+// кнопка отмены остаётся, пока задача в очереди или генерируется
+func editJobStatus(bot *tgbotapi.BotAPI, job *models.GenerationJob, status string) {
+	if job.ProgressMessageID == 0 {
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(job.ChatID, job.ProgressMessageID, jobHeader(job)+"\n"+status)
+	edit.ReplyMarkup = jobStatusMarkup(job)
+	bot.Send(edit)
+}
+
+func jobStatusMarkup(job *models.GenerationJob) *tgbotapi.InlineKeyboardMarkup {
+	if job.Status != models.JobQueued && job.Status != models.JobRunning {
+		return nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(cancelButton(job.ID)))
+	return &markup
+}
+
+// finishJobStatus переводит сообщение о ходе генерации в финальное состояние
+func finishJobStatus(bot *tgbotapi.BotAPI, job *models.GenerationJob, cause error) {
+	elapsed := formatElapsed(time.Since(job.RunningSince()))
+	var genErr *generator.GenerationError
+	switch {
+	case job.Status == models.JobCancelled:
+		editJobStatus(bot, job, "⏹ Отменено")
+	case cause == nil:
+		editJobStatus(bot, job, "✅ Готово за "+elapsed)
+	case errors.Is(cause, generator.ErrTimeout):
+		editJobStatus(bot, job, "⌛ Не уложились в отведённое время ("+elapsed+")")
+	case errors.As(cause, &genErr) && genErr.Blocked():
+		editJobStatus(bot, job, "🛡 Заблокировано фильтрами безопасности")
+	default:
+		editJobStatus(bot, job, "❌ Не удалось сгенерировать видео")
+	}
+}
+
+// progressTracker обновляет сообщение о ходе генерации на каждом опросе операции
+type progressTracker struct {
+	bot       *tgbotapi.BotAPI
+	job       *models.GenerationJob
+	eta       time.Duration
+	estimated bool // ETA посчитана по истории модели
+}
+
+func newProgressTracker(bot *tgbotapi.BotAPI, job *models.GenerationJob) *progressTracker {
+	eta, estimated := estimateDuration(job.ModelID)
+	return &progressTracker{bot: bot, job: job, eta: eta, estimated: estimated}
+}
+
+// estimateDuration — ожидаемая длительность генерации по истории модели
+func estimateDuration(modelID string) (time.Duration, bool) {
+	avg, n, err := repository.AverageJobDuration(modelID, etaSampleSize)
+	if err != nil {
+		logger.LogError("job_eta", map[string]interface{}{
+			"model": modelID,
+			"error": err.Error(),
+		})
+		return defaultETA, false
+	}
+	if n < etaMinSamples || avg <= 0 {
+		return defaultETA, false
+	}
+	return avg, true
+}
+
+func (p *progressTracker) update() {
+	editJobStatus(p.bot, p.job, p.status(time.Since(p.job.RunningSince())))
+}
+
+func (p *progressTracker) status(elapsed time.Duration) string {
+	// до готовности полоска не доходит до конца: ETA — только оценка
+	fraction := float64(elapsed) / float64(p.eta)
+	if fraction > 0.95 {
+		fraction = 0.95
+	}
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("▓", filled) + strings.Repeat("░", progressBarWidth-filled)
+
+	line := fmt.Sprintf("%s %d%%\n⏱ Прошло %s", bar, int(fraction*100), formatElapsed(elapsed))
+	switch remaining := p.eta - elapsed; {
+	case remaining <= 0:
+		line += " · дольше обычного, почти готово"
+	case p.estimated:
+		line += " · осталось ≈ " + formatElapsed(remaining)
+	default:
+		line += " · обычно до " + formatElapsed(p.eta)
+	}
+	return line
+}
+
+// formatElapsed — длительность в виде «1:05»
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
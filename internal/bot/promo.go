@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var promoCodeRe = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+const promoAddUsage = "Формат: /promo_add КОД кредиты [активаций]\n" +
+	"Без числа активаций код действует для всех, но каждый пользователь активирует его один раз.\n" +
+	"Пример: /promo_add WELCOME 50 100"
+
+// handlePromoCommand — /promo КОД активирует код сразу, /promo без кода ждёт его следующим сообщением
+func handlePromoCommand(r *request) {
+	if r.args != "" {
+		redeemPromo(r, r.args)
+		return
+	}
+	if err := cache.SetState(r.from.ID, cache.StateAwaitingPromo, nil); err != nil {
+		r.reply("⚠️ Ошибка при сохранении запроса")
+		return
+	}
+	r.reply("🎁 Введи промокод. Передумал — /cancel.")
+}
+
+// handlePromoInput — шаг awaiting_promo_code: пришёл промокод
+func handlePromoInput(r *request) {
+	code := strings.TrimSpace(r.message().Text)
+	if code == "" {
+		r.reply("⚠️ Отправь промокод текстом или /cancel")
+		return
+	}
+	if redeemPromo(r, code) {
+		cache.ClearState(r.from.ID)
+	}
+}
+
+// redeemPromo активирует промокод; false — код не подошёл по формату, и его можно ввести заново
+func redeemPromo(r *request, code string) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !promoCodeRe.MatchString(code) {
+		r.reply("⚠️ Такого промокода нет — проверь написание или отправь /cancel")
+		return false
+	}
+
+	credits, err := repository.RedeemPromoCode(r.from.ID, code)
+	switch {
+	case errors.Is(err, repository.ErrPromoNotFound):
+		r.reply("⚠️ Такого промокода нет — проверь написание или отправь /cancel")
+		return false
+	case errors.Is(err, repository.ErrPromoRedeemed):
+		r.reply("ℹ️ Ты уже активировал этот промокод")
+	case errors.Is(err, repository.ErrPromoExhausted):
+		r.reply("😢 Этот промокод больше не действует")
+	case err != nil:
+		logger.LogError("promo_redeem", map[string]interface{}{
+			"user_id": r.from.ID,
+			"code":    code,
+			"error":   err.Error(),
+		})
+		r.reply("⚠️ Не удалось активировать промокод, попробуй позже")
+	default:
+		logger.LogInfo("promo_redeemed", map[string]interface{}{
+			"user_id": r.from.ID,
+			"code":    code,
+			"credits": credits,
+		})
+		repository.LogAction(r.from.ID, "promo_redeemed", code, true, "")
+		r.reply(fmt.Sprintf("🎁 Промокод активирован: +%d кр. Баланс — /balance", credits))
+	}
+	return true
+}
+
+// handlePromoAdd — /promo_add КОД кредиты [активаций]: оператор заводит или меняет промокод
+func handlePromoAdd(bot *tgbotapi.BotAPI, chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 3 {
+		bot.Send(tgbotapi.NewMessage(chatID, promoAddUsage))
+		return
+	}
+	code := strings.ToUpper(fields[0])
+	if !promoCodeRe.MatchString(code) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Код — латиница, цифры, _ и -, от 3 до 50 символов\n\n"+promoAddUsage))
+		return
+	}
+	credits, err := strconv.Atoi(fields[1])
+	if err != nil || credits <= 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Кредиты — положительное число\n\n"+promoAddUsage))
+		return
+	}
+	maxUses := 0
+	if len(fields) == 3 {
+		if maxUses, err = strconv.Atoi(fields[2]); err != nil || maxUses <= 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Число активаций — положительное число\n\n"+promoAddUsage))
+			return
+		}
+	}
+
+	if err := repository.SavePromoCode(code, credits, maxUses); err != nil {
+		logger.LogError("promo_codes", map[string]interface{}{
+			"code":  code,
+			"error": err.Error(),
+		})
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось сохранить промокод"))
+		return
+	}
+	limit := "без ограничения активаций"
+	if maxUses > 0 {
+		limit = fmt.Sprintf("до %d активаций", maxUses)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Промокод %s сохранён: %d кр., %s", code, credits, limit)))
+}
@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/digkill/veo-telegram-bot/internal/generator"
+)
+
+// raiExplanations — что заблокировал фильтр и как переписать запрос
+var raiExplanations = map[string]string{
+	generator.CategoryChildren: "🚸 Модель не генерирует детей и подростков.\n" +
+		"Убери упоминания детей из промта или отправь фото без детей.",
+	generator.CategoryCelebrity: "🌟 Нельзя генерировать узнаваемых знаменитостей и публичных людей.\n" +
+		"Опиши персонажа своими словами без имени реального человека или используй другое фото.",
+	generator.CategoryPeople: "🧑 Модель отказалась генерировать людей или лица по этому запросу.\n" +
+		"Попробуй сцену без людей или опиши персонажа менее реалистично (мультяшный стиль, силуэт).",
+	generator.CategorySexual: "🔞 Запрос похож на откровенный контент.\n" +
+		"Убери описания наготы и интимных сцен.",
+	generator.CategoryViolence: "⚔️ Запрос содержит насилие или жестокость.\n" +
+		"Смягчи формулировки: без крови, травм и сцен насилия.",
+	generator.CategoryHate: "🚫 Запрос может быть воспринят как оскорбительный по отношению к группе людей.\n" +
+		"Переформулируй промт нейтрально.",
+	generator.CategoryDangerous: "⚠️ Запрос связан с опасными действиями (оружие, взрывчатка, вред себе).\n" +
+		"Убери эти детали из промта.",
+	generator.CategoryPersonalInfo: "🔒 В запросе есть персональные данные (имена, номера, адреса).\n" +
+		"Убери их из промта или с картинки.",
+	generator.CategoryProhibited: "🚫 Запрос нарушает правила использования Vertex AI.\n" +
+		"Переформулируй промт.",
+	generator.CategoryToxic: "🚫 Промт содержит грубые или токсичные формулировки.\n" +
+		"Переформулируй его нейтрально.",
+	generator.CategoryVulgar: "🚫 Промт содержит вульгарные выражения.\n" +
+		"Переформулируй его без них.",
+	generator.CategorySafety: "🛡 Видео не прошло проверку безопасности.\n" +
+		"Попробуй изменить сцену или формулировки.",
+	generator.CategoryOther: "🛡 Видео отфильтровано политиками безопасности Google.\n" +
+		"Попробуй изменить промт или картинку.",
+}
+
+// failureMessage — понятное пользователю объяснение, почему генерация не удалась
+func failureMessage(err error) string {
+	var genErr *generator.GenerationError
+	if errors.As(err, &genErr) && genErr.Blocked() {
+		explanation, ok := raiExplanations[genErr.Category]
+		if !ok {
+			explanation = raiExplanations[generator.CategoryOther]
+		}
+		return "❌ Видео заблокировано фильтрами безопасности.\n\n" + explanation
+	}
+	return "❌ Не удалось сгенерировать видео: " + err.Error()
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis — Redis в памяти для тестов: команды, которыми пользуется бот (GET, SET, GETDEL, DEL),
+// по протоколу RESP2, чтобы cache работал через настоящий клиент
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+// useRedis подменяет cache.Rdb клиентом к fakeRedis
+func useRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	f := &fakeRedis{values: map[string]string{}, expires: map[string]time.Time{}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	prev := cache.Rdb
+	cache.Rdb = redis.NewClient(&redis.Options{Addr: ln.Addr().String(), DisableIdentity: true})
+	t.Cleanup(func() {
+		cache.Rdb.Close()
+		cache.Rdb = prev
+		ln.Close()
+	})
+	return f
+}
+
+// get возвращает значение ключа и оставшийся TTL (0 — без срока)
+func (f *fakeRedis) get(key string) (string, time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	var ttl time.Duration
+	if exp, has := f.expires[key]; has {
+		ttl = time.Until(exp)
+	}
+	return v, ttl, ok
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, f.exec(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(v string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v) }
+
+const nilReply = "$-1\r\n"
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmd := strings.ToUpper(args[0])
+	if len(args) > 1 {
+		if exp, ok := f.expires[args[1]]; ok && time.Now().After(exp) {
+			delete(f.values, args[1])
+			delete(f.expires, args[1])
+		}
+	}
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET", "GETDEL":
+		v, ok := f.values[args[1]]
+		if !ok {
+			return nilReply
+		}
+		if cmd == "GETDEL" {
+			delete(f.values, args[1])
+			delete(f.expires, args[1])
+		}
+		return bulk(v)
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.values[key]; ok {
+				n++
+			}
+			delete(f.values, key)
+			delete(f.expires, key)
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		keepTTL, nx, xx := false, false, false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "KEEPTTL":
+				keepTTL = true
+			case "EX", "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				if strings.ToUpper(args[i]) == "PX" {
+					ttl = time.Duration(n) * time.Millisecond
+				}
+				i++
+			}
+		}
+		_, exists := f.values[key]
+		if (nx && exists) || (xx && !exists) {
+			return nilReply
+		}
+		f.values[key] = value
+		switch {
+		case ttl > 0:
+			f.expires[key] = time.Now().Add(ttl)
+		case !keepTTL:
+			delete(f.expires, key)
+		}
+		return "+OK\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// request — одно обновление от Telegram вместе с отправителем; у команды заполнены command и args
+type request struct {
+	bot    *tgbotapi.BotAPI
+	update tgbotapi.Update
+	from   *tgbotapi.User
+	chatID int64
+
+	command string // имя команды без «/» и @имени_бота
+	args    string // текст после команды
+
+	conv *cache.Conversation // шаг диалога, на котором пришло сообщение без команды
+}
+
+// message — сообщение обновления или nil для нажатий кнопок и платёжных запросов
+func (r *request) message() *tgbotapi.Message {
+	return r.update.Message
+}
+
+func (r *request) callback() *tgbotapi.CallbackQuery {
+	return r.update.CallbackQuery
+}
+
+// reply отвечает пользователю: на нажатие кнопки — всплывающей подсказкой, иначе сообщением в чат
+func (r *request) reply(text string) {
+	if cb := r.callback(); cb != nil {
+		r.bot.Request(tgbotapi.NewCallback(cb.ID, text))
+		return
+	}
+	if r.chatID != 0 {
+		r.bot.Send(tgbotapi.NewMessage(r.chatID, text))
+	}
+}
+
+type handlerFunc func(r *request)
+
+// middleware оборачивает обработчик: может ответить сам и не вызывать next
+type middleware func(next handlerFunc) handlerFunc
+
+type callbackRoute struct {
+	prefix string
+	handle handlerFunc
+}
+
+// router разбирает обновления и передаёт их обработчикам через цепочку middleware.
+// Порядок для сообщений: оплата → команда → обработчик текущего шага диалога → текст или картинка (fallback).
+type router struct {
+	chain       []middleware
+	commands    map[string]handlerFunc
+	callbacks   []callbackRoute
+	states      map[cache.State]handlerFunc
+	fallback    handlerFunc
+	payment     handlerFunc
+	preCheckout handlerFunc
+}
+
+func newRouter(chain ...middleware) *router {
+	return &router{chain: chain, commands: map[string]handlerFunc{}, states: map[cache.State]handlerFunc{}}
+}
+
+// command регистрирует /name; mw применяются только к этой команде (например, adminOnly).
+// Любая команда завершает незаконченный шаг диалога — команда начинает новое действие.
+func (rt *router) command(name string, h handlerFunc, mw ...middleware) {
+	h = wrap(h, mw)
+	rt.commands[name] = func(r *request) {
+		cache.ClearState(r.from.ID)
+		h(r)
+	}
+}
+
+// callback регистрирует обработчик кнопок, чьи данные начинаются с prefix; побеждает самый длинный префикс.
+// mw применяются только к этим кнопкам (например, ownerOnly).
+func (rt *router) callback(prefix string, h handlerFunc, mw ...middleware) {
+	rt.callbacks = append(rt.callbacks, callbackRoute{prefix: prefix, handle: wrap(h, mw)})
+}
+
+// state регистрирует обработчик сообщений на шаге диалога state (см. cache.SetState)
+func (rt *router) state(state cache.State, h handlerFunc) {
+	rt.states[state] = h
+}
+
+func wrap(h handlerFunc, chain []middleware) handlerFunc {
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+	return h
+}
+
+// dispatch прогоняет обновление через общую цепочку middleware и выбранный обработчик
+func (rt *router) dispatch(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	r := &request{bot: bot, update: update, from: update.SentFrom()}
+	if chat := update.FromChat(); chat != nil {
+		r.chatID = chat.ID
+	}
+	if r.from == nil {
+		return
+	}
+
+	h := rt.match(r)
+	if h == nil {
+		return
+	}
+	wrap(h, rt.chain)(r)
+}
+
+// match выбирает обработчик; nil — обновление не для нас
+func (rt *router) match(r *request) handlerFunc {
+	switch {
+	case r.update.PreCheckoutQuery != nil:
+		return rt.preCheckout
+
+	case r.callback() != nil:
+		var best *callbackRoute
+		for i, route := range rt.callbacks {
+			if strings.HasPrefix(r.callback().Data, route.prefix) && (best == nil || len(route.prefix) > len(best.prefix)) {
+				best = &rt.callbacks[i]
+			}
+		}
+		if best == nil {
+			return nil
+		}
+		return best.handle
+
+	case r.message() != nil:
+		msg := r.message()
+		if msg.SuccessfulPayment != nil {
+			return rt.payment
+		}
+		if msg.IsCommand() {
+			// в группах /cmd@другой_бот адресована не нам
+			if _, botName, ok := strings.Cut(msg.CommandWithAt(), "@"); ok && !strings.EqualFold(botName, r.bot.Self.UserName) {
+				return nil
+			}
+			r.command = msg.Command()
+			r.args = strings.TrimSpace(msg.CommandArguments())
+			if h, ok := rt.commands[r.command]; ok {
+				return h
+			}
+			return unknownCommand
+		}
+		return rt.conversation
+	}
+	return nil
+}
+
+// conversation передаёт сообщение обработчику текущего шага диалога, а в обычном режиме — fallback
+func (rt *router) conversation(r *request) {
+	conv, err := cache.GetState(r.from.ID)
+	if err != nil {
+		logger.LogError("conversation_state", map[string]interface{}{
+			"user_id": r.from.ID,
+			"error":   err.Error(),
+		})
+	}
+	r.conv = conv
+	if h, ok := rt.states[conv.State]; ok {
+		h(r)
+		return
+	}
+	if rt.fallback != nil {
+		rt.fallback(r)
+	}
+}
+
+func unknownCommand(r *request) {
+	r.reply("🤷 Не знаю команду /" + r.command + ". Список команд — /help")
+}
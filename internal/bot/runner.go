@@ -0,0 +1,440 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/generator"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// после стольких запусков (включая перезапуски бота) задача считается проваленной
+const maxJobAttempts = 3
+
+// startGeneration списывает кредиты, создаёт задачу и доводит её до конца.
+// lastFrameBase64 — конечный кадр для интерполяции, chainID != 0 — продолжение цепочки: готовый сегмент приклеивается к её видео.
+// Саму генерацию выполняет воркер из пула (см. pool.go).
+func startGeneration(bot *tgbotapi.BotAPI, chatID, userID int64, text, imageBase64, lastFrameBase64 string, chainID int64, preset *models.Preset) {
+	cleanPrompt, params, model, err := resolvePrompt(userID, text, preset, imageBase64 != "")
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
+		return
+	}
+	if chainID != 0 && params.Samples() > 1 {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Продолжение генерируется в одном варианте — убери тег #x"))
+		return
+	}
+	images := 0
+	if imageBase64 != "" {
+		images++
+		if lastFrameBase64 != "" {
+			images++
+		}
+	}
+	if err := checkModelSupport(model, params, images); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
+		return
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при разборе параметров"))
+		return
+	}
+
+	// цена модели — за каждый запрошенный вариант
+	credits := model.Price * params.Samples()
+	if err := repository.SubtractCredits(userID, credits); err != nil {
+		if errors.Is(err, repository.ErrInsufficientCredits) {
+			bot.Send(tgbotapi.NewMessage(chatID, "😢 Недостаточно кредитов. Пополни баланс через /buy"))
+		} else {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось списать кредиты"))
+		}
+		return
+	}
+
+	job := &models.GenerationJob{
+		UserID:          userID,
+		ChatID:          chatID,
+		Prompt:          cleanPrompt,
+		Params:          string(paramsJSON),
+		ImageBase64:     imageBase64,
+		LastFrameBase64: lastFrameBase64,
+		ModelID:         model.ID,
+		ChainID:         chainID,
+		Credits:         credits,
+	}
+	var createErr error
+	if chainID != 0 {
+		createErr = repository.CreateChainJob(job, maxExtensions)
+	} else {
+		createErr = repository.CreateJob(job)
+	}
+	if createErr != nil {
+		_ = repository.RefundCredits(userID, credits)
+		switch {
+		case errors.Is(createErr, repository.ErrChainBusy):
+			bot.Send(tgbotapi.NewMessage(chatID, chainBusyMessage))
+		case errors.Is(createErr, repository.ErrChainFull):
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Это видео уже продлено %d раз — больше нельзя", maxExtensions)))
+		default:
+			logger.LogError("job_create", map[string]interface{}{
+				"user_id": userID,
+				"error":   createErr.Error(),
+			})
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать задачу генерации"))
+		}
+		return
+	}
+
+	if preset != nil {
+		recordPresetUsage(preset, job)
+	}
+
+	balance, _ := repository.GetBalance(userID)
+	status := fmt.Sprintf("💰 У тебя %d кр. на данный момент.\n⏳ Запускаю генерацию…", balance)
+	if params.Samples() > 1 {
+		status = fmt.Sprintf("🎞️ Вариантов: %d\n", params.Samples()) + status
+	}
+	sendJobStatus(bot, job, status)
+
+	enqueueJob(job)
+}
+
+// ResumeJobs подхватывает незавершённые задачи после перезапуска бота.
+// Готовые видео доставляются сразу, остальные задачи встают в очередь раньше новых.
+func ResumeJobs(bot *tgbotapi.BotAPI) {
+	jobs, err := repository.GetUnfinishedJobs()
+	if err != nil {
+		logger.LogError("job_resume", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	for _, job := range jobs {
+		logger.LogInfo("job_resume", map[string]interface{}{
+			"job_id":  job.ID,
+			"user_id": job.UserID,
+			"status":  job.Status,
+		})
+		// у сцен раскадровки прогресс показывает отдельное сообщение
+		if job.Status != models.JobSucceeded && job.StoryID == 0 {
+			// старое сообщение о ходе генерации уехало вверх — продолжаем в новом, а у старого убираем кнопку
+			if job.ProgressMessageID != 0 {
+				bot.Send(tgbotapi.NewEditMessageText(job.ChatID, job.ProgressMessageID, jobHeader(job)+"\n♻️ Бот перезапускался — продолжение ниже"))
+			}
+			sendJobStatus(bot, job, "♻️ Бот перезапускался — продолжаю генерацию твоего видео…")
+		}
+		if job.Status == models.JobSucceeded {
+			go runJob(context.Background(), bot, job)
+			continue
+		}
+		enqueueJob(job)
+	}
+
+	resumeStories(bot)
+}
+
+// runJob проводит задачу по состояниям queued → running → succeeded → delivered
+func runJob(ctx context.Context, bot *tgbotapi.BotAPI, job *models.GenerationJob) {
+	if job.Status == models.JobQueued || job.Status == models.JobRunning {
+		if job.Attempts >= maxJobAttempts {
+			failJob(bot, job, models.JobFailed, fmt.Errorf("превышено число попыток"))
+			return
+		}
+		if err := repository.IncrementJobAttempts(job.ID); err != nil {
+			logger.LogError("job_attempts", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			})
+		}
+		job.Attempts++
+	}
+
+	var params prompt.GenerationParams
+	if err := json.Unmarshal([]byte(job.Params), &params); err != nil {
+		failJob(bot, job, models.JobFailed, fmt.Errorf("повреждены параметры задачи: %w", err))
+		return
+	}
+
+	if job.Status == models.JobQueued {
+		opName, region, err := generator.Submit(ctx, job.UserID, job.ModelID, job.Prompt, params, job.ImageBase64, job.LastFrameBase64)
+		if err != nil && ctx.Err() != nil {
+			cancelJob(bot, job)
+			return
+		}
+		if err != nil {
+			failJob(bot, job, models.JobFailed, err)
+			return
+		}
+		startedAt := time.Now()
+		if err := repository.SetJobOperation(job.ID, opName, region, startedAt); err != nil {
+			logger.LogError("job_operation", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			})
+		}
+		logger.LogInfo("job_region", map[string]interface{}{
+			"job_id": job.ID,
+			"region": region,
+		})
+		job.OperationName = opName
+		job.Region = region
+		job.StartedAt = &startedAt
+		job.Status = models.JobRunning
+	}
+
+	if job.Status == models.JobRunning {
+		progress := newProgressTracker(bot, job)
+		progress.update()
+		results, err := generator.Poll(ctx, job.UserID, job.ID, job.ModelID, job.Region, job.OperationName, job.Prompt, job.RunningSince(), progress.update)
+		if err != nil && ctx.Err() != nil {
+			cancelJob(bot, job)
+			return
+		}
+		if err != nil {
+			status := models.JobFailed
+			if errors.Is(err, generator.ErrTimeout) {
+				status = models.JobTimeout
+			}
+			failJob(bot, job, status, err)
+			return
+		}
+
+		// видео получены и оплачены — отменить задачу больше нельзя;
+		// если отмена пришла раньше, пользователю уже ответили «Отменяю…» — доводим отмену до конца
+		if !pool.detach(job.ID) {
+			cancelJob(bot, job)
+			return
+		}
+		ctx = context.WithoutCancel(ctx)
+
+		job.Videos = make([]models.JobVideo, 0, len(results))
+		for _, r := range results {
+			job.Videos = append(job.Videos, jobVideo(r.Path, r.LogID, r.Media))
+		}
+		if job.ChainID != 0 {
+			videos, err := extendChain(ctx, job)
+			if err != nil {
+				failJob(bot, job, models.JobFailed, err)
+				return
+			}
+			job.Videos = videos
+		}
+		if err := repository.CompleteJob(job.ID, job.Videos); err != nil {
+			logger.LogError("job_complete", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			})
+		}
+		job.VideoPath = job.Videos[0].Path
+		job.Status = models.JobSucceeded
+
+		// часть вариантов могла не вернуться (например, отфильтрована) — за них не платим
+		if missing := params.Samples() - len(results); missing > 0 {
+			refund := job.Credits / params.Samples() * missing
+			if err := repository.RefundCredits(job.UserID, refund); err != nil {
+				logger.LogError("job_refund", map[string]interface{}{
+					"job_id":  job.ID,
+					"user_id": job.UserID,
+					"credits": refund,
+					"error":   err.Error(),
+				})
+			}
+			bot.Send(tgbotapi.NewMessage(job.ChatID, fmt.Sprintf(
+				"⚠️ %d из %d вариантов отфильтрованы политиками безопасности — %d кр. за них возвращены.",
+				missing, params.Samples(), refund)))
+		}
+	}
+
+	if job.Status == models.JobSucceeded {
+		if job.StoryID != 0 {
+			deliverScene(bot, job)
+			return
+		}
+		deliverJob(bot, job)
+	}
+}
+
+func deliverJob(bot *tgbotapi.BotAPI, job *models.GenerationJob) {
+	if err := sendVideos(bot, job); err != nil {
+		// статус остаётся succeeded — доставку повторит ResumeJobs
+		logger.LogError("job_deliver", map[string]interface{}{
+			"job_id":  job.ID,
+			"user_id": job.UserID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	rememberResult(job)
+
+	if err := repository.MarkJobDelivered(job.ID); err != nil {
+		logger.LogError("job_deliver", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+	job.Status = models.JobDelivered
+	finishJobStatus(bot, job, nil)
+
+	newBalance, _ := repository.GetBalance(job.UserID)
+	bot.Send(tgbotapi.NewMessage(job.ChatID, fmt.Sprintf("✅ Успешно! Остаток: %d кр.", newBalance)))
+}
+
+// sendVideos отправляет одно видео или альбом вариантов с кнопками выбора любимого
+// и запоминает file_id отправленных видео в job.Videos
+func sendVideos(bot *tgbotapi.BotAPI, job *models.GenerationJob) error {
+	// локальные копии могли быть вытеснены из кэша, если доставка откладывалась
+	for _, v := range job.Videos {
+		if v.FileID != "" {
+			continue
+		}
+		if err := mediastore.Localize(context.Background(), v.Path); err != nil {
+			return err
+		}
+		if v.Thumb != "" {
+			_ = mediastore.Localize(context.Background(), v.Thumb)
+		}
+	}
+
+	if len(job.Videos) == 1 {
+		caption := "Вот твоё видео!"
+		if job.ChainID != 0 {
+			if chain, err := repository.GetChain(job.ChainID); err == nil {
+				caption = fmt.Sprintf("🎞️ Видео продлено! Сегментов: %d", chain.Segments)
+			}
+		}
+		fileID, err := sendVideo(bot, job.ChatID, job.Videos[0], caption)
+		job.Videos[0].FileID = fileID
+		return err
+	}
+
+	media := make([]interface{}, 0, len(job.Videos))
+	var favRow, extendRow []tgbotapi.InlineKeyboardButton
+	for i, v := range job.Videos {
+		item := tgbotapi.NewInputMediaVideo(videoFile(v))
+		if v.Thumb != "" && v.FileID == "" {
+			item.Thumb = tgbotapi.FilePath(v.Thumb)
+		}
+		item.Width, item.Height, item.Duration = v.Width, v.Height, int(math.Round(v.Duration))
+		item.SupportsStreaming = true
+		if i == 0 {
+			item.Caption = fmt.Sprintf("Вот твои видео! Вариантов: %d", len(job.Videos))
+		}
+		media = append(media, item)
+
+		if v.LogID > 0 {
+			favRow = append(favRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⭐ %d", i+1), fmt.Sprintf("fav_%d", v.LogID)))
+			extendRow = append(extendRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("➕ %d", i+1), fmt.Sprintf("extend_%d", v.LogID)))
+		}
+	}
+
+	sent, err := bot.SendMediaGroup(tgbotapi.NewMediaGroup(job.ChatID, media))
+	if err != nil {
+		return err
+	}
+	for i, m := range sent {
+		if i < len(job.Videos) && m.Video != nil {
+			job.Videos[i].FileID = m.Video.FileID
+		}
+	}
+
+	if len(favRow) > 0 {
+		msg := tgbotapi.NewMessage(job.ChatID, "Какой вариант понравился больше всего? ⭐ — в избранное, ➕ — продлить")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(favRow, extendRow)
+		bot.Send(msg)
+	}
+	return nil
+}
+
+// videoFile — уже загруженное в Telegram видео отправляется по file_id, иначе файлом с диска
+func videoFile(v models.JobVideo) tgbotapi.RequestFileData {
+	if v.FileID != "" {
+		return tgbotapi.FileID(v.FileID)
+	}
+	return tgbotapi.FilePath(v.Path)
+}
+
+// sendVideo отправляет одно видео с превью, размерами и кнопкой продления и возвращает его file_id.
+// В VideoConfig из tgbotapi v5.5.1 нет width/height, поэтому параметры sendVideo собираются вручную.
+func sendVideo(bot *tgbotapi.BotAPI, chatID int64, v models.JobVideo, caption string) (string, error) {
+	video := tgbotapi.NewVideo(chatID, videoFile(v))
+	video.Caption = caption
+	video.Duration = int(math.Round(v.Duration))
+	video.SupportsStreaming = true
+
+	files := []tgbotapi.RequestFile{{Name: "video", Data: video.File}}
+	if v.Thumb != "" && v.FileID == "" {
+		video.Thumb = tgbotapi.FilePath(v.Thumb)
+		files = append(files, tgbotapi.RequestFile{Name: "thumb", Data: video.Thumb})
+	}
+
+	params := tgbotapi.Params{}
+	params.AddFirstValid("chat_id", video.ChatID)
+	params.AddNonEmpty("caption", video.Caption)
+	params.AddNonZero("duration", video.Duration)
+	params.AddNonZero("width", v.Width)
+	params.AddNonZero("height", v.Height)
+	params.AddBool("supports_streaming", video.SupportsStreaming)
+	if v.LogID > 0 {
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(extendButton(v.LogID)))
+		if err := params.AddInterface("reply_markup", markup); err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := bot.UploadFiles("sendVideo", params, files)
+	if err != nil {
+		return "", err
+	}
+	var sent tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return "", fmt.Errorf("не удалось разобрать ответ sendVideo: %w", err)
+	}
+	if sent.Video == nil {
+		return "", errors.New("в ответе sendVideo нет видео")
+	}
+	return sent.Video.FileID, nil
+}
+
+// failJob завершает задачу с ошибкой и возвращает списанные кредиты
+func failJob(bot *tgbotapi.BotAPI, job *models.GenerationJob, status string, cause error) {
+	if err := repository.FailJob(job.ID, status, cause.Error()); err != nil {
+		logger.LogError("job_fail", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+	job.Status = status
+	finishJobStatus(bot, job, cause)
+
+	if status == models.JobFailed {
+		repository.LogAction(job.UserID, "generation_failed", job.Prompt, false, "")
+	}
+
+	if err := repository.RefundCredits(job.UserID, job.Credits); err != nil {
+		logger.LogError("job_refund", map[string]interface{}{
+			"job_id":  job.ID,
+			"user_id": job.UserID,
+			"credits": job.Credits,
+			"error":   err.Error(),
+		})
+	}
+
+	if job.StoryID != 0 {
+		bot.Send(tgbotapi.NewMessage(job.ChatID, fmt.Sprintf("Сцена %d: %s\n\n💰 Кредиты за сцену возвращены на баланс.",
+			job.SceneIndex+1, failureMessage(cause))))
+		updateStory(bot, job.StoryID)
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(job.ChatID, failureMessage(cause)+"\n\n💰 Кредиты возвращены на баланс."))
+}
@@ -0,0 +1,474 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/digkill/veo-telegram-bot/internal/cache"
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/media"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const storyHelpMessage = `📜 Раскадровка: пришли сценарий из нескольких сцен — каждая станет отдельным роликом, а потом я склею их в одно видео.
+
+Сцены нумеруй или разделяй строкой ---. В первой строке можно задать общие теги: формат, seed, #fade=1 для плавных переходов.
+
+Пример:
+#9:16 #fade
+1. Кот просыпается на подоконнике
+2. Кот идёт на кухню #6s
+3. Кот засыпает у миски`
+
+// блокировки раскадровок: сцены завершаются параллельно, а склеить видео нужно один раз
+var storyLocks sync.Map
+
+func storyLock(storyID int64) *sync.Mutex {
+	mu, _ := storyLocks.LoadOrStore(storyID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// handleStoryCommand — /story со сценарием в том же сообщении или без него;
+// без сценария бот ждёт его следующим сообщением (шаг awaiting_story_script)
+func handleStoryCommand(r *request) {
+	if r.args == "" {
+		if err := cache.SetState(r.from.ID, cache.StateStoryScript, nil); err != nil {
+			r.reply("⚠️ Ошибка при сохранении запроса")
+			return
+		}
+		reply := tgbotapi.NewMessage(r.chatID, "Ок, пришли сценарий следующим сообщением (или /cancel).\n\n"+storyHelpMessage)
+		reply.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+		r.bot.Send(reply)
+		return
+	}
+	confirmStory(r.bot, r.chatID, r.from.ID, r.args)
+}
+
+// handleStoryScript — сценарий пришёл отдельным сообщением; при ошибках бот ждёт исправленный сценарий
+func handleStoryScript(r *request) {
+	if confirmStory(r.bot, r.chatID, r.from.ID, r.message().Text) {
+		cache.ClearState(r.from.ID)
+	}
+}
+
+// confirmStory проверяет сценарий и показывает раскадровку с ценой перед запуском; false — сценарий не принят
+func confirmStory(bot *tgbotapi.BotAPI, chatID, userID int64, script string) bool {
+	story, model, err := parseStory(userID, script)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
+		return false
+	}
+
+	if err := cache.StoreStoryRequest(userID, script); err != nil {
+		logger.LogError("redis_store", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка при сохранении запроса"))
+		return false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎬 Раскадровка: %d сцен\n\n", len(story.Scenes))
+	for i, scene := range story.Scenes {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, scene.Prompt)
+	}
+	b.WriteString("\n⚙️ Параметры: " + describeParams(model, story.Scenes[0].Params) + "\n")
+	if story.Crossfade > 0 {
+		fmt.Fprintf(&b, "🔀 Переходы: %.1f с\n", story.Crossfade)
+	} else {
+		b.WriteString("🔀 Переходы: встык\n")
+	}
+	fmt.Fprintf(&b, "🤖 Модель: %s · %d кр. (%d кр. за сцену)", model.DisplayName(), model.Price*len(story.Scenes), model.Price)
+
+	confirmBtn := tgbotapi.NewInlineKeyboardButtonData("✅ Запустить раскадровку", fmt.Sprintf("storyconfirm_%d", userID))
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(confirmBtn))
+	bot.Send(msg)
+	return true
+}
+
+func parseStory(userID int64, script string) (*prompt.Story, config.ModelConfig, error) {
+	model := userModel(userID)
+	story, err := prompt.ParseStory(script)
+	if err != nil {
+		return nil, model, err
+	}
+	for _, scene := range story.Scenes {
+		if err := checkModelSupport(model, scene.Params, 0); err != nil {
+			return nil, model, err
+		}
+	}
+	return story, model, nil
+}
+
+func handleStoryConfirm(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	script, err := cache.TakeStoryRequest(userID)
+	if err != nil || script == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось получить сценарий — отправь его ещё раз через /story"))
+		return
+	}
+
+	startStory(bot, chatID, userID, script)
+}
+
+// startStory списывает кредиты за все сцены и запускает их задачи параллельно
+func startStory(bot *tgbotapi.BotAPI, chatID, userID int64, script string) {
+	parsed, model, err := parseStory(userID, script)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, paramsErrorMessage(err)))
+		return
+	}
+
+	credits := model.Price * len(parsed.Scenes)
+	if err := repository.SubtractCredits(userID, credits); err != nil {
+		if errors.Is(err, repository.ErrInsufficientCredits) {
+			bot.Send(tgbotapi.NewMessage(chatID, "😢 Недостаточно кредитов. Пополни баланс через /buy"))
+		} else {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось списать кредиты"))
+		}
+		return
+	}
+
+	story := &models.Story{
+		UserID:    userID,
+		ChatID:    chatID,
+		ModelID:   model.ID,
+		Scenes:    len(parsed.Scenes),
+		Crossfade: parsed.Crossfade,
+	}
+	if err := repository.CreateStory(story); err != nil {
+		logger.LogError("story_create", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		_ = repository.RefundCredits(userID, credits)
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать раскадровку"))
+		return
+	}
+
+	jobs := make([]*models.GenerationJob, 0, len(parsed.Scenes))
+	for i, scene := range parsed.Scenes {
+		paramsJSON, _ := json.Marshal(scene.Params)
+		job := &models.GenerationJob{
+			UserID:     userID,
+			ChatID:     chatID,
+			Prompt:     scene.Prompt,
+			Params:     string(paramsJSON),
+			ModelID:    model.ID,
+			StoryID:    story.ID,
+			SceneIndex: i,
+			Credits:    model.Price,
+		}
+		if err := repository.CreateJob(job); err != nil {
+			logger.LogError("job_create", map[string]interface{}{
+				"user_id":  userID,
+				"story_id": story.ID,
+				"error":    err.Error(),
+			})
+			// без всех сцен раскадровку не запускаем: созданные задачи закрываем, кредиты возвращаем целиком
+			for _, created := range jobs {
+				_ = repository.FailJob(created.ID, models.JobFailed, "раскадровка не запущена")
+			}
+			_ = repository.FailStory(story.ID)
+			_ = repository.RefundCredits(userID, credits)
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать задачи раскадровки"))
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	balance, _ := repository.GetBalance(userID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🎬 Генерирую %d сцен на %s (%d кр.)… У тебя %d кр. на данный момент.",
+		len(jobs), model.DisplayName(), credits, balance)))
+
+	text, markup := storyProgress(story, sceneMap(jobs))
+	progress := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		progress.ReplyMarkup = *markup
+	}
+	if sent, err := bot.Send(progress); err == nil {
+		story.ProgressMessageID = sent.MessageID
+		_ = repository.SetStoryProgressMessage(story.ID, sent.MessageID)
+	}
+
+	for _, job := range jobs {
+		enqueueJob(job)
+	}
+}
+
+// deliverScene отмечает сцену готовой; видео пользователь получит в составе склейки
+func deliverScene(bot *tgbotapi.BotAPI, job *models.GenerationJob) {
+	for _, v := range job.Videos {
+		_ = repository.SetLogTier(v.LogID, models.TierSegment)
+	}
+	if err := repository.MarkJobDelivered(job.ID); err != nil {
+		logger.LogError("job_deliver", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+	job.Status = models.JobDelivered
+	updateStory(bot, job.StoryID)
+}
+
+// updateStory обновляет прогресс по сценам и склеивает видео, когда готовы все сцены
+func updateStory(bot *tgbotapi.BotAPI, storyID int64) {
+	mu := storyLock(storyID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	story, err := repository.GetStory(storyID)
+	if err != nil || story.Status != models.StoryRunning {
+		return
+	}
+	jobs, err := repository.GetSceneJobs(storyID)
+	if err != nil {
+		logger.LogError("story_jobs", map[string]interface{}{
+			"story_id": storyID,
+			"error":    err.Error(),
+		})
+		return
+	}
+
+	if story.ProgressMessageID != 0 {
+		text, markup := storyProgress(story, jobs)
+		var edit tgbotapi.EditMessageTextConfig
+		if markup != nil {
+			edit = tgbotapi.NewEditMessageTextAndMarkup(story.ChatID, story.ProgressMessageID, text, *markup)
+		} else {
+			edit = tgbotapi.NewEditMessageText(story.ChatID, story.ProgressMessageID, text)
+		}
+		bot.Send(edit)
+	}
+
+	for i := 0; i < story.Scenes; i++ {
+		if job := jobs[i]; job == nil || job.Status != models.JobDelivered || len(job.Videos) == 0 {
+			return
+		}
+	}
+	stitchStory(bot, story, jobs)
+}
+
+// stitchStory склеивает готовые сцены и отправляет видео пользователю
+func stitchStory(bot *tgbotapi.BotAPI, story *models.Story, jobs map[int]*models.GenerationJob) {
+	ctx := context.Background()
+	inputs := make([]string, story.Scenes)
+	prompts := make([]string, story.Scenes)
+	scenes := make([]models.JobVideo, story.Scenes)
+	for i := 0; i < story.Scenes; i++ {
+		if err := mediastore.Localize(ctx, jobs[i].Videos[0].Path); err != nil {
+			logger.LogError("story_localize", map[string]interface{}{
+				"story_id": story.ID,
+				"scene":    i,
+				"error":    err.Error(),
+			})
+		}
+		inputs[i] = jobs[i].Videos[0].Path
+		prompts[i] = jobs[i].Prompt
+		scenes[i] = jobs[i].Videos[0]
+	}
+
+	out := fmt.Sprintf("%s/%d/story_%d.mp4", mediastore.WorkDir, story.UserID, story.ID)
+	var err error
+	if story.Crossfade > 0 {
+		err = media.Crossfade(ctx, out, story.Crossfade, inputs...)
+	} else {
+		err = media.Concat(ctx, out, inputs...)
+	}
+	if err != nil {
+		logger.LogError("story_stitch", map[string]interface{}{
+			"story_id": story.ID,
+			"user_id":  story.UserID,
+			"error":    err.Error(),
+		})
+		// сцены уже оплачены и готовы — отдаём их хотя бы по отдельности
+		bot.Send(tgbotapi.NewMessage(story.ChatID, "⚠️ Не удалось склеить сцены — отправляю их по отдельности"))
+		if err := sendVideos(bot, &models.GenerationJob{ChatID: story.ChatID, Videos: scenes}); err == nil {
+			_ = repository.CompleteStory(story.ID, "", 0)
+		}
+		return
+	}
+
+	info, err := media.Process(ctx, out)
+	if err != nil {
+		logger.LogError("story_postprocess", map[string]interface{}{
+			"story_id": story.ID,
+			"file":     out,
+			"error":    err.Error(),
+		})
+	}
+	logID, err := repository.LogGeneration(story.UserID, strings.Join(prompts, "\n---\n"), out)
+	if err != nil {
+		logger.LogError("story_log", map[string]interface{}{
+			"story_id": story.ID,
+			"error":    err.Error(),
+		})
+	}
+	thumb := ""
+	if info != nil {
+		thumb = info.ThumbPath
+		if logID > 0 {
+			_ = repository.SetLogMedia(logID, info)
+		}
+	}
+	mediastore.Persist(ctx, out, thumb)
+
+	video := jobVideo(out, logID, info)
+	if _, err := sendVideo(bot, story.ChatID, video, fmt.Sprintf("🎬 Раскадровка готова! Сцен: %d", story.Scenes)); err != nil {
+		// раскадровка остаётся running — отправку повторит ResumeJobs
+		logger.LogError("story_deliver", map[string]interface{}{
+			"story_id": story.ID,
+			"error":    err.Error(),
+		})
+		return
+	}
+	if err := repository.CompleteStory(story.ID, out, logID); err != nil {
+		logger.LogError("story_complete", map[string]interface{}{
+			"story_id": story.ID,
+			"error":    err.Error(),
+		})
+	}
+
+	balance, _ := repository.GetBalance(story.UserID)
+	bot.Send(tgbotapi.NewMessage(story.ChatID, fmt.Sprintf("✅ Успешно! Остаток: %d кр.", balance)))
+}
+
+// storyProgress — текст прогресса по сценам и кнопки повтора упавших сцен
+func storyProgress(story *models.Story, jobs map[int]*models.GenerationJob) (string, *tgbotapi.InlineKeyboardMarkup) {
+	var b strings.Builder
+	var retry []tgbotapi.InlineKeyboardButton
+	ready := 0
+
+	for i := 0; i < story.Scenes; i++ {
+		job := jobs[i]
+		icon, title := "⚠️", "сцена не создана"
+		if job != nil {
+			title = job.Prompt
+			if r := []rune(title); len(r) > 40 {
+				title = string(r[:40]) + "…"
+			}
+			switch job.Status {
+			case models.JobQueued:
+				icon = "🕓"
+			case models.JobRunning:
+				icon = "⏳"
+			case models.JobSucceeded, models.JobDelivered:
+				icon = "✅"
+				ready++
+			case models.JobFailed, models.JobTimeout:
+				icon = "❌"
+				retry = append(retry, tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("🔁 Сцена %d", i+1), fmt.Sprintf("storyretry_%d_%d", story.ID, i)))
+			}
+		}
+		fmt.Fprintf(&b, "%s %d. %s\n", icon, i+1, title)
+	}
+
+	header := fmt.Sprintf("🎬 Раскадровка: готово %d из %d сцен\n\n", ready, story.Scenes)
+	if len(retry) == 0 {
+		return header + b.String(), nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(retry)
+	return header + b.String() + "\nУпавшие сцены можно перезапустить — остальные генерировать заново не придётся.", &markup
+}
+
+// handleStoryRetry перезапускает упавшую сцену новой задачей за цену одной сцены
+func handleStoryRetry(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(cb.Data, "storyretry_"), "_")
+	if len(parts) != 2 {
+		return
+	}
+	storyID, _ := strconv.ParseInt(parts[0], 10, 64)
+	scene, _ := strconv.Atoi(parts[1])
+
+	story, err := repository.GetStory(storyID)
+	if err != nil || story.UserID != cb.From.ID || story.Status != models.StoryRunning {
+		bot.Request(tgbotapi.NewCallback(cb.ID, "⚠️ Раскадровка не найдена"))
+		return
+	}
+
+	job, err := retryScene(story, scene)
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cb.ID, err.Error()))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(cb.ID, fmt.Sprintf("🔁 Сцена %d перезапущена", scene+1)))
+
+	updateStory(bot, story.ID)
+	enqueueJob(job)
+}
+
+func retryScene(story *models.Story, scene int) (*models.GenerationJob, error) {
+	mu := storyLock(story.ID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	jobs, err := repository.GetSceneJobs(story.ID)
+	if err != nil {
+		return nil, errors.New("⚠️ Не удалось загрузить сцены")
+	}
+	prev := jobs[scene]
+	if prev == nil || (prev.Status != models.JobFailed && prev.Status != models.JobTimeout) {
+		return nil, errors.New("Эта сцена уже генерируется или готова")
+	}
+
+	price := config.Model(story.ModelID).Price
+	if err := repository.SubtractCredits(story.UserID, price); err != nil {
+		if errors.Is(err, repository.ErrInsufficientCredits) {
+			return nil, errors.New("😢 Недостаточно кредитов — пополни баланс через /buy")
+		}
+		return nil, errors.New("⚠️ Не удалось списать кредиты")
+	}
+
+	job := &models.GenerationJob{
+		UserID:     story.UserID,
+		ChatID:     story.ChatID,
+		Prompt:     prev.Prompt,
+		Params:     prev.Params,
+		ModelID:    prev.ModelID,
+		StoryID:    story.ID,
+		SceneIndex: scene,
+		Credits:    price,
+	}
+	if err := repository.CreateJob(job); err != nil {
+		_ = repository.RefundCredits(story.UserID, price)
+		return nil, errors.New("⚠️ Не удалось создать задачу")
+	}
+	return job, nil
+}
+
+// resumeStories доводит до склейки раскадровки, все сцены которых были готовы до перезапуска
+func resumeStories(bot *tgbotapi.BotAPI) {
+	stories, err := repository.GetRunningStories()
+	if err != nil {
+		logger.LogError("story_resume", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	for _, story := range stories {
+		go updateStory(bot, story.ID)
+	}
+}
+
+func sceneMap(jobs []*models.GenerationJob) map[int]*models.GenerationJob {
+	m := make(map[int]*models.GenerationJob, len(jobs))
+	for _, job := range jobs {
+		m[job.SceneIndex] = job
+	}
+	return m
+}
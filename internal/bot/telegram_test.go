@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram — Bot API для тестов: запоминает вызовы и отвечает успехом,
+// кроме методов из fail
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls []telegramCall
+	fail  map[string]bool
+}
+
+type telegramCall struct {
+	method string
+	params url.Values
+}
+
+// newTestBot поднимает заглушку Bot API и бота, который в неё ходит
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{fail: map[string]bool{}}
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	bot, err := tgbotapi.NewBotAPIWithClient("test", ts.URL+"/bot%s/%s", ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, fake
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		r.ParseMultipartForm(32 << 20)
+	} else {
+		r.ParseForm()
+	}
+
+	f.mu.Lock()
+	fail := f.fail[method]
+	if method != "getMe" {
+		f.calls = append(f.calls, telegramCall{method: method, params: r.Form})
+	}
+	f.mu.Unlock()
+
+	switch {
+	case fail:
+		fmt.Fprint(w, `{"ok":false,"error_code":500,"description":"Internal Server Error"}`)
+	case method == "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+	case strings.HasPrefix(method, "send"):
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1},"video":{"file_id":"sent","file_unique_id":"sent","width":720,"height":1280,"duration":8}}}`)
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// texts — тексты сообщений и ответов на кнопки, отправленных методом method
+func (f *fakeTelegram) texts(method string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, c := range f.calls {
+		if c.method == method {
+			texts = append(texts, c.params.Get("text"))
+		}
+	}
+	return texts
+}
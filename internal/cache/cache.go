@@ -29,17 +29,23 @@ func Init() {
 	}
 }
 
+// сколько ждёт подтверждения сохранённый запрос
+const promptTTL = 30 * time.Minute
+
 // promptData — структура хранения данных генерации
 type promptData struct {
-	Prompt      string `json:"prompt"`
-	ImageBase64 string `json:"image_base64,omitempty"`
+	Prompt          string `json:"prompt"`
+	ImageBase64     string `json:"image_base64,omitempty"`
+	LastFrameBase64 string `json:"last_frame_base64,omitempty"`
+	PresetID        int64  `json:"preset_id,omitempty"` // стилевой пресет, выбранный кнопкой
 }
 
-// StorePromptRequest сохраняет текст и изображение во временное хранилище (TTL 30 минут)
-func StorePromptRequest(userID int64, prompt string, imageBase64 string) error {
+// StorePromptRequest сохраняет текст и изображения (первый и конечный кадр) во временное хранилище (TTL 30 минут)
+func StorePromptRequest(userID int64, prompt string, imageBase64, lastFrameBase64 string) error {
 	data := promptData{
-		Prompt:      prompt,
-		ImageBase64: imageBase64,
+		Prompt:          prompt,
+		ImageBase64:     imageBase64,
+		LastFrameBase64: lastFrameBase64,
 	}
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -48,7 +54,7 @@ func StorePromptRequest(userID int64, prompt string, imageBase64 string) error {
 	}
 
 	key := fmt.Sprintf("prompt:%d", userID)
-	err = Rdb.Set(ctx, key, jsonData, 30*time.Minute).Err()
+	err = Rdb.Set(ctx, key, jsonData, promptTTL).Err()
 	if err != nil {
 		log.Printf("❌ Redis SET error for user %d: %v\n", userID, err)
 		return fmt.Errorf("redis set error: %w", err)
@@ -58,23 +64,105 @@ func StorePromptRequest(userID int64, prompt string, imageBase64 string) error {
 	return nil
 }
 
-// GetPromptData возвращает сохранённые данные по userID
-func GetPromptData(userID int64) (string, string, error) {
+// GetPromptData возвращает сохранённые данные по userID: текст, первый и конечный кадр
+func GetPromptData(userID int64) (string, string, string, error) {
 	key := fmt.Sprintf("prompt:%d", userID)
 	val, err := Rdb.Get(ctx, key).Result()
 	if err != nil {
 		log.Printf("❌ Redis GET error for user %d: %v\n", userID, err)
-		return "", "", err
+		return "", "", "", err
 	}
 
 	var data promptData
 	if err := json.Unmarshal([]byte(val), &data); err != nil {
 		log.Printf("❌ Unmarshal error for user %d: %v\n", userID, err)
-		return "", "", fmt.Errorf("unmarshal error: %w", err)
+		return "", "", "", fmt.Errorf("unmarshal error: %w", err)
 	}
 
 	log.Printf("📦 Prompt retrieved for user %d: %s\n", userID, data.Prompt)
-	return data.Prompt, data.ImageBase64, nil
+	return data.Prompt, data.ImageBase64, data.LastFrameBase64, nil
+}
+
+// TakePromptData забирает сохранённый запрос и удаляет его одной командой GETDEL:
+// из нескольких одновременных нажатий «Подтвердить» / «Прислать готовое» запрос получит только одно,
+// остальные получат redis.Nil
+func TakePromptData(userID int64) (string, string, string, error) {
+	val, err := TakePromptRequest(userID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var data promptData
+	if err := json.Unmarshal([]byte(val), &data); err != nil {
+		return "", "", "", fmt.Errorf("unmarshal error: %w", err)
+	}
+
+	log.Printf("📦 Prompt taken for user %d: %s\n", userID, data.Prompt)
+	return data.Prompt, data.ImageBase64, data.LastFrameBase64, nil
+}
+
+// TakePromptRequest забирает сохранённый запрос целиком (GETDEL), как он лежит в Redis,
+// чтобы его можно было вернуть RestorePromptRequest, если обработать не удалось
+func TakePromptRequest(userID int64) (string, error) {
+	key := fmt.Sprintf("prompt:%d", userID)
+	return Rdb.GetDel(ctx, key).Result()
+}
+
+// RestorePromptRequest возвращает запрос, забранный TakePromptRequest, со свежим TTL: пользователь
+// сможет подтвердить его ещё раз. Запрос, который пользователь успел сохранить тем временем, не затирается.
+func RestorePromptRequest(userID int64, val string) error {
+	key := fmt.Sprintf("prompt:%d", userID)
+	return Rdb.SetNX(ctx, key, val, promptTTL).Err()
+}
+
+// UpdatePromptText заменяет текст сохранённого запроса; картинки и выбранный пресет остаются, TTL не продлевается
+func UpdatePromptText(userID int64, prompt string) error {
+	return updatePrompt(userID, func(data *promptData) { data.Prompt = prompt })
+}
+
+// SetPromptPreset запоминает выбранный пресет в сохранённом запросе (0 — без пресета), не продлевая TTL
+func SetPromptPreset(userID int64, presetID int64) error {
+	return updatePrompt(userID, func(data *promptData) { data.PresetID = presetID })
+}
+
+// updatePrompt меняет сохранённый запрос в транзакции WATCH/MULTI: если запрос тем временем забрали
+// (TakePromptData) или заменили новым, запись отменяется с redis.TxFailedErr — иначе SET воскресил бы
+// уже подтверждённый запрос или затёр новый старым. XX не даёт создать ключ заново без TTL.
+func updatePrompt(userID int64, update func(*promptData)) error {
+	key := fmt.Sprintf("prompt:%d", userID)
+	return Rdb.Watch(ctx, func(tx *redis.Tx) error {
+		val, err := tx.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		var data promptData
+		if err := json.Unmarshal([]byte(val), &data); err != nil {
+			return fmt.Errorf("unmarshal error: %w", err)
+		}
+		update(&data)
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("marshal error: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.SetArgs(ctx, key, jsonData, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+		})
+		return err
+	}, key)
+}
+
+// GetPromptPreset возвращает пресет, выбранный для сохранённого запроса; 0 — не выбран или запроса нет
+func GetPromptPreset(userID int64) int64 {
+	val, err := Rdb.Get(ctx, fmt.Sprintf("prompt:%d", userID)).Result()
+	if err != nil {
+		return 0
+	}
+	var data promptData
+	if err := json.Unmarshal([]byte(val), &data); err != nil {
+		return 0
+	}
+	return data.PresetID
 }
 
 // ClearPrompt удаляет сохранённый промт
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// State — шаг диалога, на котором бот ждёт от пользователя следующее сообщение
+type State string
+
+const (
+	StateIdle            State = ""                       // обычный режим: сообщение — это промт
+	StateAwaitingEmail   State = "awaiting_email"         // email для чека; data: package — выбранный пакет кредитов
+	StatePromptEdit      State = "awaiting_prompt_edit"   // новый текст промта; data: message_id, aspect_ratio
+	StateExtendPrompt    State = "awaiting_extend_prompt" // текст продолжения видео; data: log_id
+	StateStoryScript     State = "awaiting_story_script"  // сценарий раскадровки
+	StateAwaitingSupport State = "awaiting_support"       // вопрос в поддержку
+	StateAwaitingPromo   State = "awaiting_promo_code"    // промокод на кредиты
+)
+
+// сколько живёт незавершённый шаг диалога
+const stateTTL = 30 * time.Minute
+
+// Conversation — состояние диалога пользователя: шаг и данные, нужные, чтобы продолжить его
+type Conversation struct {
+	State State             `json:"state"`
+	Data  map[string]string `json:"data,omitempty"`
+	Since time.Time         `json:"since"`
+}
+
+func stateKey(userID int64) string {
+	return fmt.Sprintf("state:%d", userID)
+}
+
+// SetState переводит диалог пользователя на шаг state; TTL отсчитывается заново
+func SetState(userID int64, state State, data map[string]string) error {
+	jsonData, err := json.Marshal(Conversation{State: state, Data: data, Since: time.Now()})
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	return Rdb.Set(context.Background(), stateKey(userID), jsonData, stateTTL).Err()
+}
+
+// GetState возвращает текущий шаг диалога; без сохранённого шага — StateIdle
+func GetState(userID int64) (*Conversation, error) {
+	val, err := Rdb.Get(context.Background(), stateKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return &Conversation{State: StateIdle}, nil
+	}
+	if err != nil {
+		return &Conversation{State: StateIdle}, err
+	}
+
+	var conv Conversation
+	if err := json.Unmarshal([]byte(val), &conv); err != nil {
+		return &Conversation{State: StateIdle}, fmt.Errorf("unmarshal error: %w", err)
+	}
+	return &conv, nil
+}
+
+// ClearState возвращает диалог в обычный режим
+func ClearState(userID int64) {
+	Rdb.Del(context.Background(), stateKey(userID))
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const storyTTL = 30 * time.Minute
+
+// StoreStoryRequest сохраняет сценарий раскадровки до подтверждения
+func StoreStoryRequest(userID int64, script string) error {
+	key := fmt.Sprintf("story:%d", userID)
+	return Rdb.Set(context.Background(), key, script, storyTTL).Err()
+}
+
+// TakeStoryRequest забирает сценарий и удаляет его одной командой GETDEL: из нескольких нажатий
+// «Запустить раскадровку» сценарий получит только одно, и кредиты спишутся один раз
+func TakeStoryRequest(userID int64) (string, error) {
+	key := fmt.Sprintf("story:%d", userID)
+	return Rdb.GetDel(context.Background(), key).Result()
+}
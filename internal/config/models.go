@@ -0,0 +1,331 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/jsonpatch"
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+)
+
+// Duration — time.Duration, которая читается из JSON строкой вида "10s" / "5m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("длительность должна быть строкой: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// PollPolicy — как часто и как долго опрашивать операцию генерации
+type PollPolicy struct {
+	InitialDelay Duration `json:"initial_delay"` // пауза перед первым опросом
+	Interval     Duration `json:"interval"`      // первый интервал между опросами
+	Multiplier   float64  `json:"multiplier"`    // рост интервала на каждом шаге
+	MaxInterval  Duration `json:"max_interval"`
+	Jitter       float64  `json:"jitter"`   // доля случайного разброса интервала, 0..1
+	Deadline     Duration `json:"deadline"` // общий лимит времени с момента запуска
+}
+
+// Режимы выдачи результата: base64 в ответе операции или файлы в Cloud Storage
+const (
+	OutputInline = "inline"
+	OutputGCS    = "gcs"
+)
+
+// OutputConfig — куда Vertex AI кладёт сгенерированные видео
+type OutputConfig struct {
+	Mode       string `json:"mode"`        // inline | gcs
+	StorageURI string `json:"storage_uri"` // gs://bucket/prefix/, обязателен для gcs
+}
+
+// ModelConfig — запись реестра: возможности модели, цена и операторские настройки
+type ModelConfig struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`  // название для пользователя
+	Family       string   `json:"family"` // veo2 | veo3; пусто — по префиксу ID
+	AspectRatios []string `json:"aspect_ratios"`
+	Durations    []int    `json:"durations"` // допустимые длительности, секунды
+	Resolutions  []string `json:"resolutions"`
+	Audio        bool     `json:"audio"`       // умеет генерировать звук
+	ImageInput   bool     `json:"image_input"` // принимает стартовый кадр
+	LastFrame    bool     `json:"last_frame"`  // принимает и конечный кадр (интерполяция между двумя картинками)
+	Price        int      `json:"price"`       // кредитов за один вариант
+
+	Polling PollPolicy   `json:"polling"`
+	Output  OutputConfig `json:"output"`
+	// RequestPatch — JSON Patch поверх тела predictLongRunning; правки default применяются первыми
+	RequestPatch []jsonpatch.Operation `json:"request_patch"`
+}
+
+// SupportsAspectRatio, SupportsDuration, SupportsResolution — проверки возможностей модели
+func (m ModelConfig) SupportsAspectRatio(ratio string) bool {
+	return slices.Contains(m.AspectRatios, ratio)
+}
+
+func (m ModelConfig) SupportsDuration(seconds int) bool {
+	return slices.Contains(m.Durations, seconds)
+}
+
+func (m ModelConfig) SupportsResolution(resolution string) bool {
+	return slices.Contains(m.Resolutions, resolution)
+}
+
+// DefaultDuration — длительность, если пользователь её не указал: максимальная из допустимых
+func (m ModelConfig) DefaultDuration() int {
+	if len(m.Durations) == 0 {
+		return 8
+	}
+	return slices.Max(m.Durations)
+}
+
+// DisplayName — Title, а если он не задан — ID
+func (m ModelConfig) DisplayName() string {
+	if m.Title != "" {
+		return m.Title
+	}
+	return m.ID
+}
+
+type modelsFile struct {
+	DefaultModel string        `json:"default_model"`
+	Default      ModelConfig   `json:"default"`
+	Models       []ModelConfig `json:"models"`
+}
+
+var defaultModelConfig = ModelConfig{
+	AspectRatios: []string{"16:9", "9:16"},
+	Durations:    []int{8},
+	Resolutions:  []string{"720p"},
+	Audio:        true,
+	ImageInput:   true,
+	Price:        150,
+	Polling: PollPolicy{
+		InitialDelay: Duration{20 * time.Second},
+		Interval:     Duration{10 * time.Second},
+		Multiplier:   1.5,
+		MaxInterval:  Duration{30 * time.Second},
+		Jitter:       0.2,
+		Deadline:     Duration{8 * time.Minute},
+	},
+	Output: OutputConfig{Mode: OutputInline},
+}
+
+var (
+	mu           sync.RWMutex
+	defaults     = defaultModelConfig
+	registry     []ModelConfig // в порядке файла — так же они показываются в /model
+	defaultModel string
+)
+
+// Load читает реестр моделей из MODELS_CONFIG (по умолчанию config/models.json).
+// Без файла реестр состоит из одной модели MODEL_ID со встроенными настройками.
+// MODEL_ID, если задан, переопределяет default_model из файла.
+func Load() error {
+	path := utils.GetEnv("MODELS_CONFIG", "config/models.json")
+	file := modelsFile{Default: defaultModelConfig}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		log.Printf("⚠️ %s не найден — используем настройки моделей по умолчанию", path)
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return fmt.Errorf("невалидный %s: %w", path, err)
+		}
+	}
+
+	file.Default = merge(file.Default, defaultModelConfig)
+	if err := validateModel("default", file.Default); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	models := make([]ModelConfig, 0, len(file.Models))
+	for _, m := range file.Models {
+		if m.ID == "" {
+			return fmt.Errorf("%s: у модели не задан id", path)
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("%s: модель %s описана дважды", path, m.ID)
+		}
+		seen[m.ID] = true
+
+		m = merge(m, file.Default)
+		if err := validateModel(m.ID, m); err != nil {
+			return err
+		}
+		models = append(models, m)
+	}
+
+	def := utils.GetEnv("MODEL_ID", file.DefaultModel)
+	if def == "" && len(models) > 0 {
+		def = models[0].ID
+	}
+	if def == "" {
+		return fmt.Errorf("не задана модель по умолчанию: укажи MODEL_ID или default_model")
+	}
+	if !seen[def] {
+		m := file.Default
+		m.ID = def
+		models = append(models, m)
+	}
+
+	mu.Lock()
+	defaults = file.Default
+	registry = models
+	defaultModel = def
+	mu.Unlock()
+	return nil
+}
+
+func MustLoad() {
+	if err := Load(); err != nil {
+		log.Fatalf("❌ Не удалось загрузить реестр моделей: %v", err)
+	}
+}
+
+// Lookup ищет модель в реестре
+func Lookup(modelID string) (ModelConfig, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, m := range registry {
+		if m.ID == modelID {
+			return m, true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+// Model возвращает модель из реестра; для неизвестной (например, убранной из конфига,
+// но оставшейся в старой задаче) — настройки секции default с этим ID
+func Model(modelID string) ModelConfig {
+	if m, ok := Lookup(modelID); ok {
+		return m
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	m := defaults
+	m.ID = modelID
+	return m
+}
+
+// Models — все модели реестра в порядке конфига
+func Models() []ModelConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Clone(registry)
+}
+
+// DefaultModel — модель для пользователей, которые не выбрали свою
+func DefaultModel() ModelConfig {
+	mu.RLock()
+	id := defaultModel
+	mu.RUnlock()
+	return Model(id)
+}
+
+// merge заполняет незаданные поля модели значениями из def
+func merge(m, def ModelConfig) ModelConfig {
+	if len(m.AspectRatios) == 0 {
+		m.AspectRatios = def.AspectRatios
+	}
+	if len(m.Durations) == 0 {
+		m.Durations = def.Durations
+	}
+	if len(m.Resolutions) == 0 {
+		m.Resolutions = def.Resolutions
+	}
+	if m.Price == 0 {
+		m.Price = def.Price
+	}
+	m.Polling = mergePolling(m.Polling, def.Polling)
+	m.Output = mergeOutput(m.Output, def.Output)
+	if m.ID != "" {
+		m.RequestPatch = append(slices.Clone(def.RequestPatch), m.RequestPatch...)
+	}
+	return m
+}
+
+func mergePolling(p, def PollPolicy) PollPolicy {
+	if p.InitialDelay.Duration == 0 {
+		p.InitialDelay = def.InitialDelay
+	}
+	if p.Interval.Duration == 0 {
+		p.Interval = def.Interval
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.MaxInterval.Duration == 0 {
+		p.MaxInterval = def.MaxInterval
+	}
+	if p.Jitter == 0 {
+		p.Jitter = def.Jitter
+	}
+	if p.Deadline.Duration == 0 {
+		p.Deadline = def.Deadline
+	}
+	return p
+}
+
+func mergeOutput(o, def OutputConfig) OutputConfig {
+	if o.Mode == "" {
+		o.Mode = def.Mode
+	}
+	if o.StorageURI == "" {
+		o.StorageURI = def.StorageURI
+	}
+	return o
+}
+
+func validateModel(modelID string, cfg ModelConfig) error {
+	if cfg.Price <= 0 {
+		return fmt.Errorf("модель %s: price должен быть больше нуля", modelID)
+	}
+	if err := validateOutput(modelID, cfg.Output); err != nil {
+		return err
+	}
+	for _, op := range cfg.RequestPatch {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("модель %s: request_patch: %w", modelID, err)
+		}
+	}
+	return nil
+}
+
+func validateOutput(modelID string, o OutputConfig) error {
+	switch o.Mode {
+	case OutputInline:
+		return nil
+	case OutputGCS:
+		if o.StorageURI == "" {
+			return fmt.Errorf("модель %s: для output.mode=gcs нужен storage_uri", modelID)
+		}
+		return nil
+	default:
+		return fmt.Errorf("модель %s: неизвестный output.mode %q", modelID, o.Mode)
+	}
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS generation_jobs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    prompt TEXT NOT NULL,
+    params TEXT,
+    image_base64 LONGTEXT,
+    model_id VARCHAR(100) NOT NULL,
+    operation_name VARCHAR(512),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    credits INT NOT NULL DEFAULT 0,
+    video_path TEXT,
+    error_message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    finished_at DATETIME NULL,
+    INDEX idx_generation_jobs_status (status),
+    INDEX idx_generation_jobs_user (user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS generation_jobs;
+-- +goose StatementEnd
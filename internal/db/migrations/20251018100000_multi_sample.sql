@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs ADD COLUMN videos TEXT NULL AFTER video_path;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE user_logs ADD COLUMN is_favorite BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_logs DROP COLUMN is_favorite;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE generation_jobs DROP COLUMN videos;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_logs
+    ADD COLUMN thumb_path VARCHAR(255) NULL,
+    ADD COLUMN width INT NULL,
+    ADD COLUMN height INT NULL,
+    ADD COLUMN duration DOUBLE NULL,
+    ADD COLUMN probe TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_logs
+    DROP COLUMN probe,
+    DROP COLUMN duration,
+    DROP COLUMN height,
+    DROP COLUMN width,
+    DROP COLUMN thumb_path;
+-- +goose StatementEnd
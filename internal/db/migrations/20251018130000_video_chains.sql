@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS video_chains (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    log_id BIGINT NOT NULL,
+    video_path TEXT NOT NULL,
+    segments INT NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_video_chains_user_log (user_id, log_id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS chain_segments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    job_id BIGINT NULL,
+    log_id BIGINT NOT NULL,
+    video_path TEXT NOT NULL,
+    position INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_chain_segments_job (chain_id, job_id),
+    INDEX idx_chain_segments_chain (chain_id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE generation_jobs ADD COLUMN chain_id BIGINT NULL AFTER model_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs DROP COLUMN chain_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chain_segments;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS video_chains;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS stories (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    model_id VARCHAR(100) NOT NULL,
+    scenes INT NOT NULL,
+    crossfade DOUBLE NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    progress_message_id INT NULL,
+    video_path TEXT NULL,
+    log_id BIGINT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_stories_status (status)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    ADD COLUMN story_id BIGINT NULL AFTER chain_id,
+    ADD COLUMN scene_index INT NOT NULL DEFAULT 0 AFTER story_id,
+    ADD INDEX idx_generation_jobs_story (story_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    DROP INDEX idx_generation_jobs_story,
+    DROP COLUMN scene_index,
+    DROP COLUMN story_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stories;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs ADD COLUMN last_frame_base64 LONGTEXT NULL AFTER image_base64;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs DROP COLUMN last_frame_base64;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs ADD COLUMN region VARCHAR(150) NULL AFTER operation_name;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs DROP COLUMN region;
+-- +goose StatementEnd
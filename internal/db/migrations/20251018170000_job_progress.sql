@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    ADD COLUMN started_at DATETIME NULL AFTER created_at,
+    ADD COLUMN progress_message_id BIGINT NULL AFTER region,
+    ADD INDEX idx_generation_jobs_model_finished (model_id, finished_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    DROP INDEX idx_generation_jobs_model_finished,
+    DROP COLUMN progress_message_id,
+    DROP COLUMN started_at;
+-- +goose StatementEnd
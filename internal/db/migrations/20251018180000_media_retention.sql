@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_logs
+    ADD COLUMN media_tier VARCHAR(20) NOT NULL DEFAULT 'video',
+    ADD COLUMN purged_at DATETIME NULL,
+    ADD INDEX idx_user_logs_retention (purged_at, media_tier, timestamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_logs
+    DROP INDEX idx_user_logs_retention,
+    DROP COLUMN purged_at,
+    DROP COLUMN media_tier;
+-- +goose StatementEnd
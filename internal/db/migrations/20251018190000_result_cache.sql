@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS result_cache (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    cache_key CHAR(64) NOT NULL,
+    job_id BIGINT NOT NULL,
+    model_id VARCHAR(100) NOT NULL,
+    credits INT NOT NULL,
+    videos TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_result_cache_user_key (user_id, cache_key)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS cache_hits (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    cache_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    credits_charged INT NOT NULL DEFAULT 0,
+    credits_saved INT NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_cache_hits_cache (cache_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS cache_hits;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS result_cache;
+-- +goose StatementEnd
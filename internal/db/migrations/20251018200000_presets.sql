@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS presets (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    prompt_prefix TEXT,
+    prompt_suffix TEXT,
+    aspect_ratio VARCHAR(10),
+    negative_prompt TEXT,
+    model_id VARCHAR(100),
+    active BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_presets_name (name)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS preset_usage (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    preset_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    job_id BIGINT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_preset_usage_preset (preset_id, created_at)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS preset_usage;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS presets;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- support codes всех отфильтрованных вариантов через запятую могут не влезть в 64 символа
+ALTER TABLE generation_errors MODIFY COLUMN support_code TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE generation_errors SET support_code = LEFT(support_code, 64) WHERE CHAR_LENGTH(support_code) > 64;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE generation_errors MODIFY COLUMN support_code VARCHAR(64) NULL;
+-- +goose StatementEnd
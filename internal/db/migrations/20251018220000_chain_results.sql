@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- строка user_logs со склейкой, которая получилась после сегмента: по ней находится позиция любого видео цепочки
+ALTER TABLE chain_segments ADD COLUMN result_log_id BIGINT NULL AFTER position;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_chain_segments_result ON chain_segments (result_log_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE chain_segments SET result_log_id = log_id WHERE position = 1;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE chain_segments s JOIN video_chains c ON c.id = s.chain_id AND c.segments = s.position
+SET s.result_log_id = c.log_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chain_segments DROP INDEX idx_chain_segments_result;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE chain_segments DROP COLUMN result_log_id;
+-- +goose StatementEnd
@@ -0,0 +1,302 @@
+// Package fakevertex — локальная заглушка Vertex AI (predictLongRunning / fetchPredictOperation)
+// со сценариями для тестов генератора и ручных запусков бота без Google Cloud.
+package fakevertex
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SampleMP4 — маленькое валидное видео (32×32, 1 с, H.264), которое отдаёт заглушка
+//
+//go:embed sample.mp4
+var SampleMP4 []byte
+
+// Сценарии ответа на запрос генерации
+const (
+	ScenarioSuccess   = "success"   // видео после PollsUntilDone опросов
+	ScenarioRAIBlock  = "rai_block" // все варианты отфильтрованы RAI
+	ScenarioQuota     = "quota"     // predictLongRunning отвечает 429 RESOURCE_EXHAUSTED
+	ScenarioMalformed = "malformed" // fetchPredictOperation отдаёт битый JSON
+	ScenarioTimeout   = "timeout"   // операция никогда не завершается
+)
+
+// Scenario — как заглушка отвечает на один запрос генерации
+type Scenario struct {
+	Kind           string
+	PollsUntilDone int    // сколько опросов операция остаётся незавершённой
+	SupportCode    string // для rai_block, по умолчанию 58061214 (дети)
+}
+
+type operation struct {
+	id         string
+	scenario   Scenario
+	polls      int
+	samples    int
+	storageURI string
+	cancelled  bool
+}
+
+// Server — http.Handler заглушки: подходит и для httptest.NewServer, и для cmd/fakevertex
+type Server struct {
+	Default Scenario
+
+	mu       sync.Mutex
+	script   []Scenario
+	ops      map[string]*operation
+	objects  map[string][]byte // "bucket/object" → содержимое, для output.mode=gcs
+	requests [][]byte
+	seq      int
+}
+
+func New(def Scenario) *Server {
+	if def.Kind == "" {
+		def.Kind = ScenarioSuccess
+	}
+	return &Server{
+		Default: def,
+		ops:     map[string]*operation{},
+		objects: map[string][]byte{},
+	}
+}
+
+// Push ставит сценарии в очередь: каждый следующий predictLongRunning забирает первый из них
+func (s *Server) Push(scenarios ...Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, scenarios...)
+}
+
+// Requests — тела всех полученных predictLongRunning, по порядку
+func (s *Server) Requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":predictLongRunning"):
+		s.predict(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":fetchPredictOperation"):
+		s.fetch(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":cancel"):
+		s.cancel(w, r)
+	case r.URL.Path == "/token" || r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "fake-token",
+			"expires_in":   3600,
+			"token_type":   "Bearer",
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/"):
+		s.object(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown path "+r.URL.Path)
+	}
+}
+
+// predictRequest — поля запроса, которые влияют на ответ заглушки
+type predictRequest struct {
+	Instances []struct {
+		Prompt string `json:"prompt"`
+	} `json:"instances"`
+	Parameters struct {
+		SampleCount int    `json:"sampleCount"`
+		StorageURI  string `json:"storageUri"`
+	} `json:"parameters"`
+}
+
+func (s *Server) predict(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	var req predictRequest
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Instances) == 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid predictLongRunning body")
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, body)
+	scenario := s.Default
+	if len(s.script) > 0 {
+		scenario, s.script = s.script[0], s.script[1:]
+	}
+	// для ручных прогонов сценарий можно выбрать прямо в промте: «… fake:rai_block»
+	for _, kind := range []string{ScenarioSuccess, ScenarioRAIBlock, ScenarioQuota, ScenarioMalformed, ScenarioTimeout} {
+		if strings.Contains(req.Instances[0].Prompt, "fake:"+kind) {
+			scenario.Kind = kind
+		}
+	}
+
+	if scenario.Kind == ScenarioQuota {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED",
+			"Quota exceeded for aiplatform.googleapis.com/online_prediction_requests_per_base_model")
+		return
+	}
+
+	s.seq++
+	model := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1 : strings.LastIndex(r.URL.Path, ":")]
+	id := fmt.Sprintf("op-%d", s.seq)
+	name := fmt.Sprintf("projects/fake/locations/fake/publishers/google/models/%s/operations/%s", model, id)
+	samples := req.Parameters.SampleCount
+	if samples < 1 {
+		samples = 1
+	}
+	s.ops[name] = &operation{id: id, scenario: scenario, samples: samples, storageURI: req.Parameters.StorageURI}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"name": name})
+}
+
+func (s *Server) fetch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OperationName string `json:"operationName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.ops[req.OperationName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "operation "+req.OperationName+" not found")
+		return
+	}
+
+	op.polls++
+	if op.cancelled {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name":  req.OperationName,
+			"done":  true,
+			"error": map[string]interface{}{"code": 1, "message": "Operation was cancelled."},
+		})
+		return
+	}
+	if op.scenario.Kind == ScenarioTimeout || op.polls <= op.scenario.PollsUntilDone {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"name": req.OperationName})
+		return
+	}
+
+	switch op.scenario.Kind {
+	case ScenarioMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"name": "`+req.OperationName+`", "done": tr`)
+
+	case ScenarioRAIBlock:
+		code := op.scenario.SupportCode
+		if code == "" {
+			code = "58061214"
+		}
+		reasons := make([]string, op.samples)
+		for i := range reasons {
+			reasons[i] = "Veo could not generate videos because the input image violates Vertex AI's usage guidelines. " +
+				"If you think this was an error, send feedback. Support codes: " + code
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name": req.OperationName,
+			"done": true,
+			"response": map[string]interface{}{
+				"@type":                   "type.googleapis.com/cloud.ai.large_models.vision.GenerateVideoResponse",
+				"raiMediaFilteredCount":   op.samples,
+				"raiMediaFilteredReasons": reasons,
+			},
+		})
+
+	default:
+		videos := make([]map[string]string, op.samples)
+		for i := range videos {
+			if op.storageURI != "" {
+				uri := fmt.Sprintf("%s/%s/sample_%d.mp4", strings.TrimSuffix(op.storageURI, "/"), op.id, i)
+				s.objects[strings.TrimPrefix(uri, "gs://")] = SampleMP4
+				videos[i] = map[string]string{"gcsUri": uri, "mimeType": "video/mp4"}
+			} else {
+				videos[i] = map[string]string{
+					"bytesBase64Encoded": base64.StdEncoding.EncodeToString(SampleMP4),
+					"mimeType":           "video/mp4",
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name": req.OperationName,
+			"done": true,
+			"response": map[string]interface{}{
+				"@type":  "type.googleapis.com/cloud.ai.large_models.vision.GenerateVideoResponse",
+				"videos": videos,
+			},
+		})
+	}
+}
+
+// cancel отменяет операцию: /v1/{operationName}:cancel
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":cancel")
+
+	s.mu.Lock()
+	op, ok := s.ops[name]
+	if ok {
+		op.cancelled = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "operation "+name+" not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// object отдаёт объекты, «записанные» в storageUri, как Cloud Storage JSON API (?alt=media)
+func (s *Server) object(w http.ResponseWriter, r *http.Request) {
+	// /storage/v1/b/{bucket}/o/{object}
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/storage/v1/b/")
+	bucket, object, ok := strings.Cut(rest, "/o/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "bad object path")
+		return
+	}
+	bucket, _ = url.PathUnescape(bucket)
+	object, _ = url.PathUnescape(object)
+
+	s.mu.Lock()
+	data, ok := s.objects[bucket+"/"+object]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "no such object: "+bucket+"/"+object)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp4")
+	_, _ = w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, grpcStatus, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"status":  grpcStatus,
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+)
+
+// backoff выдаёт паузы между опросами операции по политике модели
+type backoff struct {
+	policy  config.PollPolicy
+	current time.Duration
+}
+
+func newBackoff(policy config.PollPolicy) *backoff {
+	return &backoff{policy: policy}
+}
+
+// next возвращает паузу перед очередным опросом; elapsed — сколько прошло с запуска задачи
+func (b *backoff) next(elapsed time.Duration) time.Duration {
+	if b.current == 0 {
+		b.current = b.policy.Interval.Duration
+		// после перезапуска начальная пауза могла уже пройти
+		if wait := b.policy.InitialDelay.Duration - elapsed; wait > 0 {
+			return wait
+		}
+		return 0
+	}
+
+	wait := b.current
+	b.current = time.Duration(float64(b.current) * b.policy.Multiplier)
+	if max := b.policy.MaxInterval.Duration; max > 0 && b.current > max {
+		b.current = max
+	}
+	return withJitter(wait, b.policy.Jitter)
+}
+
+// withJitter разносит интервал на ±jitter, чтобы параллельные задачи не опрашивали API синхронно
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	delta := (rand.Float64()*2 - 1) * jitter * float64(d)
+	return d + time.Duration(delta)
+}
+
+// sleepContext ждёт d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
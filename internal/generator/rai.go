@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+	"github.com/digkill/veo-telegram-bot/internal/vertex"
+)
+
+// Категории фильтров Responsible AI, по которым бот объясняет пользователю причину блокировки
+const (
+	CategoryChildren     = "children"
+	CategoryCelebrity    = "celebrity"
+	CategoryPeople       = "people"
+	CategorySexual       = "sexual"
+	CategoryViolence     = "violence"
+	CategoryHate         = "hate"
+	CategoryDangerous    = "dangerous"
+	CategoryPersonalInfo = "personal_info"
+	CategoryProhibited   = "prohibited"
+	CategoryToxic        = "toxic"
+	CategoryVulgar       = "vulgar"
+	CategorySafety       = "safety"
+	CategoryOther        = "other"
+)
+
+// supportCodeCategories — support codes из документации Veo «Responsible AI and usage guidelines»
+var supportCodeCategories = map[string]string{
+	"58061214": CategoryChildren,
+	"17301594": CategoryChildren,
+	"29310472": CategoryCelebrity,
+	"15236754": CategoryCelebrity,
+	"39322892": CategoryPeople,
+	"90789179": CategorySexual,
+	"63429089": CategorySexual,
+	"43188360": CategorySexual,
+	"61493863": CategoryViolence,
+	"56562880": CategoryViolence,
+	"57734940": CategoryHate,
+	"22137204": CategoryHate,
+	"62263041": CategoryDangerous,
+	"92201652": CategoryPersonalInfo,
+	"89371032": CategoryProhibited,
+	"49114662": CategoryProhibited,
+	"72817394": CategoryProhibited,
+	"78610348": CategoryToxic,
+	"32635315": CategoryVulgar,
+	"64151117": CategorySafety,
+	"42237218": CategorySafety,
+	"74803281": CategoryOther,
+	"29578790": CategoryOther,
+	"42876398": CategoryOther,
+}
+
+// keywordCategories — запасной вариант, если в отказе RAI нет известного support code.
+// Слова сравниваются целиком, иначе «face» находится в «interface», а «hate» — в «whatever».
+var keywordCategories = []struct {
+	re       *regexp.Regexp
+	category string
+}{
+	{keywordRe(`child|children|minors?`), CategoryChildren},
+	{keywordRe(`celebrit(?:y|ies)|prominent people|famous`), CategoryCelebrity},
+	{keywordRe(`personal information`), CategoryPersonalInfo},
+	{keywordRe(`persons?|people|faces?`), CategoryPeople},
+	{keywordRe(`sexual(?:ly)?`), CategorySexual},
+	{keywordRe(`violence|violent`), CategoryViolence},
+	{keywordRe(`hate|hateful`), CategoryHate},
+	{keywordRe(`dangerous`), CategoryDangerous},
+	{keywordRe(`prohibited`), CategoryProhibited},
+	{keywordRe(`toxic`), CategoryToxic},
+	{keywordRe(`vulgar`), CategoryVulgar},
+	{keywordRe(`usage guidelines|safety`), CategorySafety},
+}
+
+func keywordRe(words string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b(?:` + words + `)\b`)
+}
+
+// raiRejectionRe — признаки отказа по политикам RAI в тексте ошибки операции
+var raiRejectionRe = regexp.MustCompile(`(?i)usage guidelines|support codes?:`)
+
+var supportCodesRe = regexp.MustCompile(`(?i)support codes?:\s*([\d,\s]+)`)
+
+// GenerationError — генерация не удалась на стороне Vertex AI.
+// Category заполнена, если результат заблокирован фильтрами RAI.
+type GenerationError struct {
+	Code         int
+	SupportCodes []string
+	Category     string
+	Message      string // исходный текст от API; пользователю не показывается, если есть Category
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("⚠️ Генерация не удалась: %s", e.Message)
+}
+
+// Blocked — результат отфильтрован политиками безопасности
+func (e *GenerationError) Blocked() bool {
+	return e.Category != ""
+}
+
+// classify разбирает текст ошибки или причины фильтрации RAI.
+// rai — текст заведомо является отказом RAI: только тогда категория угадывается по словам.
+func classify(code int, message string, rai bool) *GenerationError {
+	e := &GenerationError{Code: code, Message: message}
+
+	if m := supportCodesRe.FindStringSubmatch(message); m != nil {
+		for _, c := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+			e.SupportCodes = append(e.SupportCodes, c)
+			if e.Category == "" {
+				e.Category = supportCodeCategories[c]
+			}
+		}
+	}
+
+	if e.Category == "" && rai {
+		for _, k := range keywordCategories {
+			if k.re.MatchString(message) {
+				e.Category = k.category
+				break
+			}
+		}
+	}
+
+	// неизвестный support code — всё равно блокировка, просто без подробностей
+	if e.Category == "" && len(e.SupportCodes) > 0 {
+		e.Category = CategoryOther
+	}
+	return e
+}
+
+// recordError сохраняет ошибку в generation_errors и пишет её в лог
+func recordError(telegramID int64, prompt string, e *GenerationError) {
+	logger.LogError("generator", map[string]interface{}{
+		"type":          "generation_error",
+		"code":          e.Code,
+		"message":       e.Message,
+		"support_codes": e.SupportCodes,
+		"category":      e.Category,
+		"user_id":       telegramID,
+		"prompt":        prompt,
+	})
+
+	if err := repository.LogGenerationError(telegramID, prompt, e.Code, strings.Join(e.SupportCodes, ","), e.Message); err != nil {
+		logger.LogError("generator", map[string]interface{}{
+			"type":    "generation_errors_insert",
+			"error":   err.Error(),
+			"user_id": telegramID,
+		})
+	}
+}
+
+// classifyOperationError разбирает ошибку операции; обычные ошибки API блокировкой не считаются
+func classifyOperationError(code int, message string) *GenerationError {
+	return classify(code, message, raiRejectionRe.MatchString(message))
+}
+
+// filteredErrors — причины, по которым RAI отбросил часть или все варианты
+func filteredErrors(response *vertex.PredictResponse) []*GenerationError {
+	if response.RaiMediaFilteredCount == 0 && len(response.RaiMediaFilteredReasons) == 0 {
+		return nil
+	}
+
+	var errs []*GenerationError
+	for _, reason := range response.RaiMediaFilteredReasons {
+		e := classify(0, reason, true)
+		if e.Category == "" {
+			e.Category = CategoryOther
+		}
+		errs = append(errs, e)
+	}
+	if len(errs) == 0 {
+		errs = append(errs, &GenerationError{
+			Category: CategoryOther,
+			Message:  fmt.Sprintf("отфильтровано вариантов: %d", response.RaiMediaFilteredCount),
+		})
+	}
+	return errs
+}
+
+// logFailure пишет в user_logs блокировку (generation_blocked) или ошибку API (generation_error)
+func logFailure(telegramID int64, prompt string, e *GenerationError) {
+	action := "generation_error"
+	if e.Blocked() {
+		action = "generation_blocked"
+	}
+	repository.LogAction(telegramID, action, prompt, false, "")
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/imageproc"
+	"github.com/digkill/veo-telegram-bot/internal/jsonpatch"
+	promptdsl "github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/vertex"
+)
+
+// buildRequest собирает типизированное тело predictLongRunning для семейства модели.
+// lastFrameBase64 — конечный кадр для интерполяции; передаётся, только если модель его поддерживает.
+func buildRequest(model config.ModelConfig, prompt string, params promptdsl.GenerationParams, imageBase64, lastFrameBase64 string) *vertex.PredictRequest {
+	instance := vertex.Instance{
+		Prompt: prompt,
+		Image:  inlineImage(imageBase64),
+	}
+	if model.LastFrame {
+		instance.LastFrame = inlineImage(lastFrameBase64)
+	}
+
+	base := vertex.Veo2Parameters{
+		AspectRatio:           params.AspectRatio,
+		SampleCount:           params.Samples(),
+		DurationSeconds:       params.DurationSeconds,
+		Seed:                  params.Seed,
+		NegativePrompt:        params.NegativePrompt,
+		PersonGeneration:      "allow_all",
+		EnablePromptRewriting: true,
+		AddWatermark:          false,
+		IncludeRaiReason:      true,
+	}
+	if base.DurationSeconds == 0 {
+		base.DurationSeconds = model.DefaultDuration()
+	}
+	if model.Output.Mode == config.OutputGCS {
+		base.StorageURI = model.Output.StorageURI
+	}
+
+	family := model.Family
+	if family == "" {
+		family = vertex.FamilyOf(model.ID)
+	}
+
+	req := &vertex.PredictRequest{Instances: []vertex.Instance{instance}}
+	switch family {
+	case vertex.FamilyVeo2:
+		req.Parameters = &base
+	default:
+		req.Parameters = &vertex.Veo3Parameters{
+			Veo2Parameters: base,
+			GenerateAudio:  model.Audio && params.Audio(),
+			Resolution:     params.Resolution,
+		}
+	}
+	return req
+}
+
+// encodeRequest сериализует запрос и применяет операторские правки request_patch из конфига модели
+func encodeRequest(req *vertex.PredictRequest, patch []jsonpatch.Operation) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err = jsonpatch.Apply(body, patch)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный request_patch: %w", err)
+	}
+	return body, nil
+}
+
+// inlineImage — картинка в base64 с MIME-типом по сигнатуре; nil, если картинки нет
+func inlineImage(encoded string) *vertex.Image {
+	image := strings.TrimSpace(encoded)
+	if image == "" {
+		return nil
+	}
+	// картинки нормализует бот, но у задач, созданных раньше, формат мог быть любым
+	mimeType, err := imageproc.SniffBase64(image)
+	if err != nil {
+		mimeType = "image/jpeg"
+	}
+	return &vertex.Image{
+		BytesBase64Encoded: image,
+		MimeType:           mimeType,
+	}
+}
@@ -1,232 +1,257 @@
 package generator
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/digkill/veo-telegram-bot/internal/auth"
+	"github.com/digkill/veo-telegram-bot/internal/config"
 	"github.com/digkill/veo-telegram-bot/internal/db"
 	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/media"
+	"github.com/digkill/veo-telegram-bot/internal/mediastore"
+	"github.com/digkill/veo-telegram-bot/internal/metrics"
+	"github.com/digkill/veo-telegram-bot/internal/objstore"
+	promptdsl "github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
 	"github.com/digkill/veo-telegram-bot/internal/utils"
+	"github.com/digkill/veo-telegram-bot/internal/vertex"
+	"io"
+	"log"
 	"os"
-	"os/exec"
-	"strings"
-	"text/template"
+	"sync"
 	"time"
 )
 
+const (
+	requestTimeout  = 30 * time.Second
+	tokenExpirySkew = 5 * time.Minute
+)
+
+// ErrTimeout — операция не завершилась за отведённое время
+var ErrTimeout = errors.New("видео не сгенерировалось за отведённое время")
+
+// регионы Vertex AI и источник GCS-объектов; задаются Init из окружения или Configure
 var (
-	projectID   = utils.MustGetEnv("PROJECT_ID")
-	locationID  = utils.MustGetEnv("LOCATION_ID")
-	apiEndpoint = utils.MustGetEnv("API_ENDPOINT")
-	modelID     = utils.MustGetEnv("MODEL_ID")
+	setupOnce sync.Once
+	router    *vertex.Router
+	fetcher   objstore.Fetcher
 )
 
-func extractAspectRatio(prompt string) (string, string) {
-	lower := strings.ToLower(prompt)
-	switch {
-	case strings.Contains(lower, "#9:16"):
-		return "9:16", strings.ReplaceAll(prompt, "#9:16", "")
-	case strings.Contains(lower, "#16:9"):
-		return "16:9", strings.ReplaceAll(prompt, "#16:9", "")
-	default:
-		return "16:9", prompt
-	}
+func init() {
+	metrics.Func("vertex_regions", func() interface{} { return RegionStatus() })
 }
 
-func GenerateVideo(prompt string, telegramID int64, imageBase64 string) (string, error) {
-	aspectRatio, cleanPrompt := extractAspectRatio(prompt)
+// Init настраивает доступ к Vertex AI и Cloud Storage из окружения (PROJECT_ID, VERTEX_REGIONS, GOOGLE_*).
+// Вызывается при старте бота, чтобы ошибки конфигурации всплыли сразу; иначе сработает при первой генерации.
+func Init() {
+	setupOnce.Do(func() {
+		tokens := auth.NewCachedSource(auth.MustFromEnv(), tokenExpirySkew)
+		router = newRouter(tokens.Token)
+		fetcher = newFetcher(tokens.Token)
+	})
+}
 
-	tplPath := "templates/request_without_image.tpl.json"
-	if strings.TrimSpace(imageBase64) != "" {
-		tplPath = "templates/request_with_image.tpl.json"
+// Configure подставляет готовые регионы и источник GCS-объектов вместо окружения —
+// например, заглушку fakevertex в тестах
+func Configure(r *vertex.Router, f objstore.Fetcher) {
+	setupOnce.Do(func() {})
+	if r.OnRegionError == nil {
+		r.OnRegionError = logRegionFailover
 	}
+	router, fetcher = r, f
+}
 
-	tplBytes, err := os.ReadFile(tplPath)
-	if err != nil {
-		return "", fmt.Errorf("не удалось прочитать шаблон %s: %w", tplPath, err)
-	}
-	tpl, err := template.New("request").Parse(string(tplBytes))
-	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга шаблона: %w", err)
-	}
+// Result — одно сохранённое видео и его строка в user_logs
+type Result struct {
+	Path  string
+	LogID int64
+	Media *media.Info // nil, если постобработка не удалась — видео отправляется как есть
+}
 
-	var buf bytes.Buffer
-	err = tpl.Execute(&buf, map[string]string{
-		"Prompt":      strings.TrimSpace(cleanPrompt),
-		"AspectRatio": aspectRatio,
-		"Image64":     strings.TrimSpace(imageBase64),
-	})
+// Submit отправляет запрос predictLongRunning и возвращает имя операции и регион, который её принял
+func Submit(ctx context.Context, telegramID int64, model string, prompt string, params promptdsl.GenerationParams, imageBase64, lastFrameBase64 string) (opName string, region string, err error) {
+	cfg := config.Model(model)
+	body, err := encodeRequest(buildRequest(cfg, prompt, params, imageBase64, lastFrameBase64), cfg.RequestPatch)
 	if err != nil {
-		return "", fmt.Errorf("ошибка шаблона: %w", err)
-	}
-
-	var jsonTest map[string]interface{}
-	if err := json.Unmarshal(buf.Bytes(), &jsonTest); err != nil {
 		logger.LogError("generator", map[string]interface{}{
-			"type":    "invalid_json",
+			"type":    "request_patch",
 			"user_id": telegramID,
+			"model":   model,
 			"error":   err.Error(),
-			"raw":     buf.String(),
 		})
-		return "", fmt.Errorf("❌ Невалидный JSON: %s", err.Error())
+		return "", "", err
 	}
 
-	// 💾 Создаём временный файл
-	tmpFile := fmt.Sprintf("./tmp/request_%d.json", telegramID)
-	if err := os.WriteFile(tmpFile, buf.Bytes(), 0644); err != nil {
-		return "", fmt.Errorf("не удалось сохранить временный JSON: %w", err)
-	}
-	defer os.Remove(tmpFile) // удалим после использования
-
-	logger.Logf("generator", map[string]interface{}{
+	logger.LogInfo("generator", map[string]interface{}{
 		"type":    "request_payload",
 		"user_id": telegramID,
 		"prompt":  prompt,
-		"json":    buf.String(),
+		"json":    string(body),
 	})
 
-	cmd := exec.Command("curl", "-s", "-X", "POST",
-		"-H", "Content-Type: application/json",
-		"-H", "Authorization: Bearer "+getAccessToken(),
-		fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:predictLongRunning",
-			apiEndpoint, projectID, locationID, modelID),
-		"-d", "@"+tmpFile,
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
+	// таймаут — на каждый регион отдельно, иначе до запасного региона очередь не дойдёт
+	Init()
+	op, used, err := router.PredictLongRunning(ctx, model, body)
+	if err != nil {
 		logger.LogError("generator", map[string]interface{}{
-			"type":    "curl_error",
-			"stderr":  stderr.String(),
+			"type":    "predict_error",
+			"error":   err.Error(),
 			"user_id": telegramID,
 		})
-		return "", fmt.Errorf("ошибка curl: %w", err)
+		return "", "", fmt.Errorf("ошибка запроса к Vertex AI: %w", err)
 	}
 
-	// продолжение анализа stdout как обычно
-	out := stdout.Bytes()
-	logger.Logf("generator", map[string]interface{}{
-		"type":     "curl_response",
-		"user_id":  telegramID,
-		"response": string(out),
+	logger.LogInfo("generator", map[string]interface{}{
+		"type":        "operation_id",
+		"user_id":     telegramID,
+		"operationID": op.Name,
+		"region":      used.Name(),
 	})
+	return op.Name, used.Name(), nil
+}
 
-	var resp map[string]interface{}
-	if err := json.Unmarshal(out, &resp); err != nil {
-		return "", err
-	}
-	opID, ok := resp["name"].(string)
-	if !ok {
+// Cancel просит Vertex AI остановить операцию; ошибка не фатальна — опрос всё равно уже прекращён
+func Cancel(telegramID int64, region, opID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	Init()
+	err := router.CancelOperation(ctx, region, opID)
+	if err != nil {
 		logger.LogError("generator", map[string]interface{}{
-			"type":    "missing_operation_id",
-			"raw":     string(out),
-			"user_id": telegramID,
+			"type":        "cancel_operation",
+			"user_id":     telegramID,
+			"operationID": opID,
+			"region":      region,
+			"error":       err.Error(),
 		})
-		return "", fmt.Errorf("не удалось извлечь operation ID")
 	}
+	return err
+}
 
-	logger.Logf("generator", map[string]interface{}{
-		"type":        "operation_id",
-		"user_id":     telegramID,
-		"operationID": opID,
-	})
-
-	for i := 0; i < 24; i++ {
-		time.Sleep(10 * time.Second)
-		fetchOut, err := fetchOperation(opID)
-		if err != nil {
-			return "", err
+// Poll опрашивает операцию задачи jobID в регионе region по политике модели и сохраняет все варианты на диск.
+// startedAt — момент запуска операции: от него отсчитывается общий дедлайн.
+// onPoll, если задан, вызывается после каждого опроса, на котором операция ещё не завершилась.
+// Временные ошибки опроса (сеть, 429, 5xx) только логируются — опрос продолжается до дедлайна.
+// При отмене ctx возвращается ctx.Err(), при истечении дедлайна — ErrTimeout.
+func Poll(ctx context.Context, telegramID, jobID int64, model string, region string, opID string, prompt string, startedAt time.Time, onPoll func()) ([]Result, error) {
+	Init()
+	policy := config.Model(model).Polling
+	deadline := startedAt.Add(policy.Deadline.Duration)
+	b := newBackoff(policy)
+
+	for attempt := 0; ; attempt++ {
+		// хотя бы один опрос делаем всегда — даже если задача возобновлена после дедлайна
+		if attempt > 0 && !time.Now().Before(deadline) {
+			break
 		}
 
-		var fetchResp map[string]interface{}
-		if err := json.Unmarshal(fetchOut, &fetchResp); err != nil {
-			return "", err
+		wait := b.next(time.Since(startedAt))
+		if remaining := time.Until(deadline); wait > remaining {
+			wait = remaining
+		}
+		if !sleepContext(ctx, wait) {
+			return nil, ctx.Err()
 		}
 
-		if errData, exists := fetchResp["error"].(map[string]interface{}); exists {
-			message := errData["message"].(string)
-			code := int(errData["code"].(float64))
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		fetchResp, err := router.FetchPredictOperation(reqCtx, region, model, opID)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !vertex.Retriable(err) {
+				return nil, err
+			}
+			// операция продолжает идти в Vertex AI — сбой сети или 5xx не повод бросать оплаченную генерацию
 			logger.LogError("generator", map[string]interface{}{
-				"type":    "generation_error",
-				"code":    code,
-				"message": message,
-				"user_id": telegramID,
-				"prompt":  prompt,
+				"type":        "poll_error",
+				"user_id":     telegramID,
+				"operationID": opID,
+				"region":      region,
+				"attempt":     attempt + 1,
+				"error":       err.Error(),
 			})
-			_, _ = db.DB.Exec(`
-				INSERT INTO user_logs (user_id, action_type, prompt, success)
-				VALUES (?, 'generation_blocked', ?, 0)`, telegramID, prompt)
-			return "", fmt.Errorf("⚠️ Генерация не удалась: %s", message)
+			continue
 		}
 
-		if response, ok := fetchResp["response"].(map[string]interface{}); ok {
-			videosRaw, ok := response["videos"]
-			if !ok {
-				logger.LogError("generator", map[string]interface{}{
-					"type":    "missing_videos_field",
-					"payload": response,
-					"user_id": telegramID,
-				})
-				continue // Пробуем снова на следующем шаге
-			}
-
-			videos, ok := videosRaw.([]interface{})
-			if !ok || len(videos) == 0 {
-				logger.LogError("generator", map[string]interface{}{
-					"type":    "invalid_videos_format",
-					"payload": videosRaw,
-					"user_id": telegramID,
-				})
-				continue
-			}
+		if fetchResp.Error != nil {
+			genErr := classifyOperationError(fetchResp.Error.Code, fetchResp.Error.Message)
+			recordError(telegramID, prompt, genErr)
+			logFailure(telegramID, prompt, genErr)
+			return nil, genErr
+		}
 
-			video, ok := videos[0].(map[string]interface{})
-			if !ok {
-				logger.LogError("generator", map[string]interface{}{
-					"type":    "invalid_video_entry",
-					"payload": videos[0],
-					"user_id": telegramID,
-				})
-				continue
+		if response := fetchResp.Response; response != nil {
+			// по одной причине на каждый отфильтрованный вариант
+			filtered := filteredErrors(response)
+			for _, e := range filtered {
+				recordError(telegramID, prompt, e)
 			}
 
-			videoBase64, ok := video["bytesBase64Encoded"].(string)
-			if !ok {
-				logger.LogError("generator", map[string]interface{}{
-					"type":    "missing_bytesBase64Encoded",
-					"payload": video,
-					"user_id": telegramID,
-				})
-				continue
+			var results []Result
+			for i, video := range response.Videos {
+				if video.BytesBase64Encoded == "" && video.GcsURI == "" {
+					logger.LogError("generator", map[string]interface{}{
+						"type":    "missing_video_data",
+						"payload": video,
+						"user_id": telegramID,
+					})
+					continue
+				}
+
+				filename, err := saveVideo(ctx, telegramID, jobID, i, video)
+				if err != nil {
+					return nil, err
+				}
+
+				info := postprocess(ctx, telegramID, filename)
+
+				logID, err := repository.LogGeneration(telegramID, prompt, filename)
+				if err != nil {
+					logger.LogError("generator", map[string]interface{}{
+						"type":    "log_generation",
+						"error":   err.Error(),
+						"user_id": telegramID,
+					})
+				}
+				if info != nil && logID > 0 {
+					if err := repository.SetLogMedia(logID, info); err != nil {
+						logger.LogError("generator", map[string]interface{}{
+							"type":    "log_media",
+							"error":   err.Error(),
+							"user_id": telegramID,
+						})
+					}
+				}
+				thumb := ""
+				if info != nil {
+					thumb = info.ThumbPath
+				}
+				mediastore.Persist(ctx, filename, thumb)
+				results = append(results, Result{Path: filename, LogID: logID, Media: info})
 			}
 
-			// декодируем, сохраняем файл
-			videoData, err := base64.StdEncoding.DecodeString(videoBase64)
-			if err != nil {
-				return "", fmt.Errorf("decode error: %w", err)
+			if len(results) > 0 {
+				return results, nil
 			}
 
-			dir := fmt.Sprintf("storage/media/%d", telegramID)
-			_ = os.MkdirAll(dir, 0755)
-
-			filename := fmt.Sprintf("%s/video_%d.mp4", dir, time.Now().Unix())
-			if err := os.WriteFile(filename, videoData, 0644); err != nil {
-				return "", err
+			// операция завершилась, но ни одного видео нет
+			genErr := &GenerationError{Message: "Vertex AI не вернул ни одного видео"}
+			if len(filtered) > 0 {
+				genErr = filtered[0]
+			} else {
+				recordError(telegramID, prompt, genErr)
 			}
-
-			_, _ = db.DB.Exec(`
-		INSERT INTO user_logs (user_id, action_type, prompt, success, video_path)
-		VALUES (?, 'generation', ?, 1, ?)`,
-				telegramID, prompt, filename,
-			)
-
-			return filename, nil
+			logFailure(telegramID, prompt, genErr)
+			return nil, genErr
 		}
 
+		if onPoll != nil {
+			onPoll()
+		}
 	}
 
 	_, _ = db.DB.Exec(`
@@ -240,26 +265,111 @@ func GenerateVideo(prompt string, telegramID int64, imageBase64 string) (string,
 		"prompt":  prompt,
 		"user_id": telegramID,
 	})
-	return "", fmt.Errorf("видео не сгенерировалось за отведённое время")
+	return nil, ErrTimeout
 }
 
-func fetchOperation(opID string) ([]byte, error) {
-	jsonBody := fmt.Sprintf(`{"operationName": "%s"}`, opID)
-	cmd := exec.Command("curl", "-s", "-X", "POST",
-		"-H", "Content-Type: application/json;  charset=utf-8",
-		"-H", "Authorization: Bearer "+getAccessToken(),
-		fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:fetchPredictOperation",
-			apiEndpoint, projectID, locationID, modelID),
-		"-d", "@-",
-	)
-	cmd.Stdin = strings.NewReader(jsonBody)
-	return cmd.Output()
+// saveVideo сохраняет видео из ответа: декодирует base64 или скачивает из Cloud Storage
+func saveVideo(ctx context.Context, telegramID, jobID int64, index int, video vertex.Video) (string, error) {
+	dir := fmt.Sprintf("%s/%d", mediastore.WorkDir, telegramID)
+	_ = os.MkdirAll(dir, 0755)
+	// ID задачи в имени: две задачи пользователя, завершившиеся в одну секунду, не затрут файлы друг друга
+	filename := fmt.Sprintf("%s/video_%d_%d_%d.mp4", dir, jobID, time.Now().Unix(), index+1)
+
+	if video.BytesBase64Encoded != "" {
+		videoData, err := base64.StdEncoding.DecodeString(video.BytesBase64Encoded)
+		if err != nil {
+			return "", fmt.Errorf("decode error: %w", err)
+		}
+		if err := os.WriteFile(filename, videoData, 0644); err != nil {
+			return "", err
+		}
+		return filename, nil
+	}
+
+	body, err := fetcher.Fetch(ctx, video.GcsURI)
+	if err != nil {
+		logger.LogError("generator", map[string]interface{}{
+			"type":    "gcs_fetch_error",
+			"uri":     video.GcsURI,
+			"error":   err.Error(),
+			"user_id": telegramID,
+		})
+		return "", fmt.Errorf("не удалось скачать видео из Cloud Storage: %w", err)
+	}
+	defer body.Close()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return "", fmt.Errorf("не удалось скачать видео из Cloud Storage: %w", err)
+	}
+	return filename, f.Close()
 }
 
-func getAccessToken() string {
-	out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+// postprocess прогоняет видео через ffprobe/ffmpeg; ошибки не фатальны — видео уйдёт без метаданных
+func postprocess(ctx context.Context, telegramID int64, filename string) *media.Info {
+	info, err := media.Process(ctx, filename)
 	if err != nil {
-		panic("❌ Не удалось получить access token: " + err.Error())
+		logger.LogError("generator", map[string]interface{}{
+			"type":    "postprocess_error",
+			"file":    filename,
+			"error":   err.Error(),
+			"user_id": telegramID,
+		})
+	}
+	return info
+}
+
+// newRouter собирает регионы из VERTEX_REGIONS; без него — один регион из LOCATION_ID и API_ENDPOINT.
+// VERTEX_BASE_URL позволяет направить все регионы на локальную заглушку вместо Google Cloud.
+func newRouter(token vertex.TokenFunc) *vertex.Router {
+	projectID := utils.MustGetEnv("PROJECT_ID")
+	var regions []*vertex.Region
+	region := func(baseURL, project, location string, weight int) *vertex.Region {
+		client := vertex.NewClient(utils.GetEnv("VERTEX_BASE_URL", baseURL), project, location, token)
+		client.HTTPClient.Timeout = requestTimeout
+		return vertex.NewRegion(client, weight)
+	}
+
+	if spec := os.Getenv("VERTEX_REGIONS"); spec != "" {
+		specs, err := vertex.ParseRegions(spec, projectID)
+		if err != nil {
+			log.Fatalf("❌ VERTEX_REGIONS: %v", err)
+		}
+		for _, s := range specs {
+			regions = append(regions, region("https://"+s.LocationID+"-aiplatform.googleapis.com", s.ProjectID, s.LocationID, s.Weight))
+		}
+	} else {
+		regions = append(regions, region("https://"+utils.MustGetEnv("API_ENDPOINT"), projectID, utils.MustGetEnv("LOCATION_ID"), 1))
+	}
+
+	r := vertex.NewRouter(regions...)
+	r.OnRegionError = logRegionFailover
+	return r
+}
+
+func logRegionFailover(region *vertex.Region, err error) {
+	logger.LogError("generator", map[string]interface{}{
+		"type":   "region_failover",
+		"region": region.Name(),
+		"error":  err.Error(),
+	})
+}
+
+// RegionStatus — состояние регионов Vertex AI (для метрик и админки)
+func RegionStatus() []vertex.RegionStatus {
+	Init()
+	return router.Status()
+}
+
+// newFetcher выбирает источник GCS-объектов: GCS_FETCHER=local читает файлы из GCS_LOCAL_ROOT
+func newFetcher(token objstore.TokenFunc) objstore.Fetcher {
+	if os.Getenv("GCS_FETCHER") == "local" {
+		return &objstore.LocalFetcher{Root: utils.GetEnv("GCS_LOCAL_ROOT", "storage/gcs")}
 	}
-	return strings.TrimSpace(string(out))
+	return objstore.NewGCSFetcher(utils.GetEnv("GCS_BASE_URL", "https://storage.googleapis.com"), token)
 }
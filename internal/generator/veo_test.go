@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/config"
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/fakevertex"
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/objstore"
+	promptdsl "github.com/digkill/veo-telegram-bot/internal/prompt"
+	"github.com/digkill/veo-telegram-bot/internal/vertex"
+)
+
+const (
+	fastModel    = "veo-test"
+	timeoutModel = "veo-test-timeout"
+)
+
+// политики опроса в миллисекундах, чтобы сценарии проходили за доли секунды
+const testModels = `{
+  "default_model": "veo-test",
+  "default": {
+    "polling": {"initial_delay": "1ms", "interval": "10ms", "max_interval": "20ms", "jitter": 0.1, "deadline": "10s"}
+  },
+  "models": [
+    {"id": "veo-test", "family": "veo3"},
+    {"id": "veo-test-timeout", "family": "veo3", "polling": {"deadline": "300ms"}}
+  ]
+}`
+
+var testDB *fakeDB
+
+// TestMain запускает тесты во временном каталоге: видео, логи и реестр моделей пишутся туда
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "generator-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("models.json", []byte(testModels), 0644); err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("MODELS_CONFIG", "models.json")
+	os.Setenv("MODEL_ID", "")
+
+	logger.Init()
+	if err := config.Load(); err != nil {
+		log.Fatal(err)
+	}
+	db.DB, testDB = openFakeDB()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func fakeToken(ctx context.Context) (string, error) {
+	return "fake-token", nil
+}
+
+// useFake направляет генератор в заглушки: по одному региону на сервер
+func useFake(t *testing.T, servers ...*fakevertex.Server) *vertex.Router {
+	t.Helper()
+	var regions []*vertex.Region
+	for i, s := range servers {
+		ts := httptest.NewServer(s)
+		t.Cleanup(ts.Close)
+		// у первого региона огромный вес: запрос уходит в него, а остальные — запасные
+		weight := 1
+		if i == 0 {
+			weight = 1_000_000
+		}
+		regions = append(regions, vertex.NewRegion(vertex.NewClient(ts.URL, "fake", fmt.Sprintf("region-%d", i+1), fakeToken), weight))
+	}
+	r := vertex.NewRouter(regions...)
+	Configure(r, &objstore.LocalFetcher{Root: t.TempDir()})
+	return r
+}
+
+func submit(t *testing.T, model string) (opName, region string) {
+	t.Helper()
+	clean, params, err := promptdsl.Parse("a red fox runs through the snow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opName, region, err = Submit(context.Background(), 1, model, clean, params, "", "")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return opName, region
+}
+
+func TestPollSuccessAfterPolls(t *testing.T) {
+	fake := fakevertex.New(fakevertex.Scenario{})
+	fake.Push(fakevertex.Scenario{Kind: fakevertex.ScenarioSuccess, PollsUntilDone: 3})
+	useFake(t, fake)
+
+	opName, region := submit(t, fastModel)
+	polls := 0
+	results, err := Poll(context.Background(), 1, 1, fastModel, region, opName, "fox", time.Now(), func() { polls++ })
+	if err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if polls != 3 {
+		t.Errorf("onPoll вызван %d раз, ожидалось 3", polls)
+	}
+	if len(results) != 1 {
+		t.Fatalf("получено %d видео, ожидалось 1", len(results))
+	}
+	data, err := os.ReadFile(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, fakevertex.SampleMP4) {
+		t.Error("сохранённое видео не совпадает с ответом заглушки")
+	}
+	if results[0].LogID == 0 {
+		t.Error("генерация не записана в user_logs")
+	}
+}
+
+func TestPollRAIBlock(t *testing.T) {
+	useFake(t, fakevertex.New(fakevertex.Scenario{Kind: fakevertex.ScenarioRAIBlock}))
+	before := testDB.executed("INSERT INTO generation_errors")
+
+	opName, region := submit(t, fastModel)
+	_, err := Poll(context.Background(), 1, 1, fastModel, region, opName, "fox", time.Now(), nil)
+
+	var genErr *GenerationError
+	if !errors.As(err, &genErr) {
+		t.Fatalf("ожидалась GenerationError, получено %v", err)
+	}
+	if !genErr.Blocked() || genErr.Category != CategoryChildren {
+		t.Errorf("категория %q, ожидалась %q", genErr.Category, CategoryChildren)
+	}
+	if len(genErr.SupportCodes) != 1 || genErr.SupportCodes[0] != "58061214" {
+		t.Errorf("support codes %v, ожидался 58061214", genErr.SupportCodes)
+	}
+	if testDB.executed("INSERT INTO generation_errors") == before {
+		t.Error("блокировка не записана в generation_errors")
+	}
+}
+
+func TestSubmitQuotaFailover(t *testing.T) {
+	exhausted := fakevertex.New(fakevertex.Scenario{Kind: fakevertex.ScenarioQuota})
+	healthy := fakevertex.New(fakevertex.Scenario{})
+	r := useFake(t, exhausted, healthy)
+
+	opName, region := submit(t, fastModel)
+	if region != r.Regions[1].Name() {
+		t.Fatalf("операцию принял регион %s, ожидался запасной %s", region, r.Regions[1].Name())
+	}
+	if len(exhausted.Requests()) != 1 || len(healthy.Requests()) != 1 {
+		t.Errorf("запросов: %d в исчерпанный регион, %d в запасной; ожидалось по одному",
+			len(exhausted.Requests()), len(healthy.Requests()))
+	}
+	if st := r.Status(); st[0].Healthy || st[0].Failures != 1 {
+		t.Errorf("исчерпанный регион не выведен из ротации: %+v", st[0])
+	}
+
+	// опрос идёт в регион, который принял операцию
+	if _, err := Poll(context.Background(), 1, 1, fastModel, region, opName, "fox", time.Now(), nil); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+}
+
+func TestPollMalformedResponse(t *testing.T) {
+	useFake(t, fakevertex.New(fakevertex.Scenario{Kind: fakevertex.ScenarioMalformed}))
+
+	opName, region := submit(t, fastModel)
+	_, err := Poll(context.Background(), 1, 1, fastModel, region, opName, "fox", time.Now(), nil)
+	if err == nil {
+		t.Fatal("битый JSON должен вернуть ошибку")
+	}
+	if errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "невалидный JSON") {
+		t.Errorf("неожиданная ошибка: %v", err)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	useFake(t, fakevertex.New(fakevertex.Scenario{Kind: fakevertex.ScenarioTimeout}))
+	before := testDB.executed("generation_timeout")
+
+	opName, region := submit(t, timeoutModel)
+	started := time.Now()
+	_, err := Poll(context.Background(), 1, 1, timeoutModel, region, opName, "fox", started, nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ожидалась ErrTimeout, получено %v", err)
+	}
+	deadline := config.Model(timeoutModel).Polling.Deadline.Duration
+	if elapsed := time.Since(started); elapsed < deadline || elapsed > deadline+time.Second {
+		t.Errorf("опрос завершился через %v, дедлайн %v", elapsed, deadline)
+	}
+	if testDB.executed("generation_timeout") == before {
+		t.Error("таймаут не записан в user_logs")
+	}
+}
+
+func TestPollSameSecondJobsKeepSeparateFiles(t *testing.T) {
+	useFake(t, fakevertex.New(fakevertex.Scenario{}))
+
+	var paths []string
+	for jobID := int64(1); jobID <= 2; jobID++ {
+		opName, region := submit(t, fastModel)
+		results, err := Poll(context.Background(), 1, jobID, fastModel, region, opName, "fox", time.Now(), nil)
+		if err != nil {
+			t.Fatalf("Poll: %v", err)
+		}
+		paths = append(paths, results[0].Path)
+	}
+	if paths[0] == paths[1] {
+		t.Errorf("видео двух задач сохранены в один файл %s", paths[0])
+	}
+}
@@ -0,0 +1,193 @@
+// Package imageproc готовит входные картинки для image-to-video: определяет формат,
+// приводит к формату кадра модели и ограничивает размер
+package imageproc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Способы привести картинку к формату кадра
+const (
+	FitCrop = "crop" // обрезать края по центру
+	FitPad  = "pad"  // вписать целиком и добить чёрными полями
+)
+
+const (
+	MaxInputBytes  = 20 << 20 // больше Bot API всё равно не отдаёт через getFile
+	maxOutputBytes = 10 << 20
+	// больше не декодируем: маленький файл может заявить огромные размеры, и RGBA-буфер займёт гигабайты
+	maxInputPixels = 50_000_000
+	jpegQuality    = 90
+	ratioTolerance = 0.01
+)
+
+// ErrUnsupported — формат картинки не JPEG, PNG или WebP
+var ErrUnsupported = errors.New("поддерживаются только JPEG, PNG и WebP")
+
+// размеры кадра 720p для каждого формата; больше Veo всё равно не использует
+var frameSizes = map[string]image.Point{
+	"16:9": {X: 1280, Y: 720},
+	"9:16": {X: 720, Y: 1280},
+}
+
+// Result — картинка, готовая к отправке в Vertex AI
+type Result struct {
+	Data     []byte
+	MimeType string
+	Width    int
+	Height   int
+	Fit      string // как приводили к формату; пусто — картинка подошла без изменений
+}
+
+// Sniff определяет MIME-тип по сигнатуре файла
+func Sniff(data []byte) (string, error) {
+	switch mime := http.DetectContentType(data); mime {
+	case "image/jpeg", "image/png", "image/webp":
+		return mime, nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+// SniffBase64 определяет MIME-тип картинки, закодированной в base64, по первым байтам
+func SniffBase64(encoded string) (string, error) {
+	// 32 символа base64 — 24 байта, этого хватает для сигнатур JPEG, PNG и WebP
+	prefix := encoded
+	if len(prefix) > 32 {
+		prefix = prefix[:32]
+	}
+	data, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil {
+		return "", fmt.Errorf("невалидный base64: %w", err)
+	}
+	return Sniff(data)
+}
+
+// Normalize приводит картинку к соотношению сторон aspect (обрезкой или полями),
+// уменьшает до 720p и перекодирует в JPEG, если это нужно. JPEG и PNG, которые
+// уже подходят, возвращаются как есть.
+func Normalize(data []byte, aspect string, fit string) (*Result, error) {
+	if len(data) > MaxInputBytes {
+		return nil, fmt.Errorf("картинка больше %d МБ", MaxInputBytes>>20)
+	}
+	mime, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	frame, ok := frameSizes[aspect]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный формат кадра %s", aspect)
+	}
+	if fit == "" {
+		fit = FitCrop
+	}
+
+	cfg, err := decodeConfig(data, mime)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать картинку: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxInputPixels {
+		return nil, fmt.Errorf("картинка %d×%d слишком большая — максимум %d Мп", cfg.Width, cfg.Height, maxInputPixels/1_000_000)
+	}
+
+	src, err := decode(data, mime)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать картинку: %w", err)
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	ratio := float64(frame.X) / float64(frame.Y)
+
+	if mime != "image/webp" && len(data) <= maxOutputBytes && w <= frame.X && h <= frame.Y &&
+		math.Abs(float64(w)/float64(h)-ratio) <= ratio*ratioTolerance {
+		return &Result{Data: data, MimeType: mime, Width: w, Height: h}, nil
+	}
+
+	// область исходника, которая попадёт в кадр (crop), или холст вокруг него (pad)
+	var area image.Rectangle
+	switch fit {
+	case FitPad:
+		cw, ch := w, h
+		if float64(w)/float64(h) > ratio {
+			ch = int(math.Round(float64(w) / ratio))
+		} else {
+			cw = int(math.Round(float64(h) * ratio))
+		}
+		area = image.Rect(0, 0, cw, ch)
+	default:
+		cw, ch := w, h
+		if float64(w)/float64(h) > ratio {
+			cw = int(math.Round(float64(h) * ratio))
+		} else {
+			ch = int(math.Round(float64(w) / ratio))
+		}
+		x0, y0 := b.Min.X+(w-cw)/2, b.Min.Y+(h-ch)/2
+		area = image.Rect(x0, y0, x0+cw, y0+ch)
+	}
+
+	// только уменьшаем: маленькие картинки Veo растянет сам
+	scale := math.Min(1, float64(frame.X)/float64(area.Dx()))
+	outW := int(math.Round(float64(area.Dx()) * scale))
+	outH := int(math.Round(float64(area.Dy()) * scale))
+	dst := image.NewRGBA(image.Rect(0, 0, outW, outH))
+
+	if fit == FitPad {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+		iw := int(math.Round(float64(w) * scale))
+		ih := int(math.Round(float64(h) * scale))
+		x0, y0 := (outW-iw)/2, (outH-ih)/2
+		draw.CatmullRom.Scale(dst, image.Rect(x0, y0, x0+iw, y0+ih), src, b, draw.Over, nil)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, area, draw.Over, nil)
+	}
+
+	var buf bytes.Buffer
+	for quality := jpegQuality; ; quality -= 15 {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxOutputBytes || quality <= 45 {
+			break
+		}
+	}
+
+	return &Result{Data: buf.Bytes(), MimeType: "image/jpeg", Width: outW, Height: outH, Fit: fit}, nil
+}
+
+// decodeConfig читает только заголовок картинки: размеры без декодирования пикселей
+func decodeConfig(data []byte, mime string) (image.Config, error) {
+	r := bytes.NewReader(data)
+	switch mime {
+	case "image/png":
+		return png.DecodeConfig(r)
+	case "image/webp":
+		return webp.DecodeConfig(r)
+	default:
+		return jpeg.DecodeConfig(r)
+	}
+}
+
+func decode(data []byte, mime string) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch mime {
+	case "image/png":
+		return png.Decode(r)
+	case "image/webp":
+		return webp.Decode(r)
+	default:
+		return jpeg.Decode(r)
+	}
+}
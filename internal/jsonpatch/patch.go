@@ -0,0 +1,158 @@
+// Package jsonpatch применяет к JSON-документу операции add / replace / remove из RFC 6902.
+// Этого подмножества достаточно, чтобы операторы могли подправить тело запроса к Vertex AI.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operation — одна операция JSON Patch
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Validate проверяет операцию без применения — для проверки конфига при старте
+func (o Operation) Validate() error {
+	switch o.Op {
+	case "add", "replace":
+		if len(o.Value) == 0 {
+			return fmt.Errorf("%s %s: нет value", o.Op, o.Path)
+		}
+		var v interface{}
+		if err := json.Unmarshal(o.Value, &v); err != nil {
+			return fmt.Errorf("%s %s: невалидный value: %w", o.Op, o.Path, err)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("неподдерживаемая операция %q", o.Op)
+	}
+	if _, err := splitPointer(o.Path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Apply применяет операции по порядку и возвращает новый документ
+func Apply(doc []byte, ops []Operation) ([]byte, error) {
+	if len(ops) == 0 {
+		return doc, nil
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, err
+		}
+		tokens, _ := splitPointer(op.Path)
+
+		var value interface{}
+		if op.Op != "remove" {
+			_ = json.Unmarshal(op.Value, &value)
+		}
+
+		var err error
+		root, err = apply(root, tokens, op.Op, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// splitPointer разбирает JSON Pointer (RFC 6901) на токены
+func splitPointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("путь %q должен начинаться с /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func apply(node interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, fmt.Errorf("нельзя удалить корень документа")
+		}
+		return value, nil
+	}
+
+	key, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, exists := n[key]
+		if len(rest) > 0 {
+			if !exists {
+				return nil, fmt.Errorf("нет ключа %q", key)
+			}
+			updated, err := apply(child, rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[key] = updated
+			return n, nil
+		}
+		switch op {
+		case "add":
+			n[key] = value
+		case "replace":
+			if !exists {
+				return nil, fmt.Errorf("нет ключа %q", key)
+			}
+			n[key] = value
+		case "remove":
+			if !exists {
+				return nil, fmt.Errorf("нет ключа %q", key)
+			}
+			delete(n, key)
+		}
+		return n, nil
+
+	case []interface{}:
+		if len(rest) == 0 && op == "add" && key == "-" {
+			return append(n, value), nil
+		}
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx > len(n) || (idx == len(n) && !(op == "add" && len(rest) == 0)) {
+			return nil, fmt.Errorf("индекс %q вне массива", key)
+		}
+		if len(rest) > 0 {
+			updated, err := apply(n[idx], rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[idx] = updated
+			return n, nil
+		}
+		switch op {
+		case "add":
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+		case "replace":
+			n[idx] = value
+		case "remove":
+			n = append(n[:idx], n[idx+1:]...)
+		}
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("по пути %q нет объекта или массива", key)
+	}
+}
@@ -139,6 +139,17 @@ func LogResponse(resp interface{}) {
 	writeJSONLog(entry, defaultLogPath)
 }
 
+func LogInfo(message string, ctx map[string]interface{}) {
+	entry := map[string]interface{}{
+		"type":    "info",
+		"message": message,
+	}
+	for k, v := range ctx {
+		entry[k] = v
+	}
+	writeJSONLog(entry, defaultLogPath)
+}
+
 func LogError(errMsg string, ctx map[string]interface{}) {
 	entry := map[string]interface{}{
 		"type":    "error",
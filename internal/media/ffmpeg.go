@@ -0,0 +1,308 @@
+// Package media — постобработка сгенерированных видео через ffprobe/ffmpeg
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/utils"
+)
+
+// лимит Bot API на загрузку файла — 50 МБ
+const defaultUploadLimit = 50 << 20
+
+var (
+	ffmpegPath  = utils.GetEnv("FFMPEG_PATH", "ffmpeg")
+	ffprobePath = utils.GetEnv("FFPROBE_PATH", "ffprobe")
+	uploadLimit = int64(utils.GetEnvInt("TELEGRAM_UPLOAD_LIMIT", defaultUploadLimit))
+)
+
+// Info — параметры видео по данным ffprobe и путь к превью
+type Info struct {
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Duration  float64 `json:"duration"` // секунды
+	Codec     string  `json:"codec"`
+	HasAudio  bool    `json:"has_audio"`
+	Size      int64   `json:"size"`
+	ThumbPath string  `json:"thumb_path,omitempty"`
+	Probe     string  `json:"-"` // сырой JSON ffprobe, хранится в user_logs
+}
+
+// Process снимает параметры видео, пережимает его с faststart, если файл больше
+// лимита загрузки Telegram, и сохраняет превью рядом с видео (*.jpg)
+func Process(ctx context.Context, path string) (*Info, error) {
+	info, err := Probe(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size > uploadLimit {
+		if err := Transcode(ctx, path, uploadLimit, info.Duration); err != nil {
+			return nil, err
+		}
+		if info, err = Probe(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	thumb, err := Thumbnail(ctx, path, info.Duration/2)
+	if err != nil {
+		return info, err
+	}
+	info.ThumbPath = thumb
+	return info, nil
+}
+
+// Probe читает размеры, длительность и кодек первого видеопотока
+func Probe(ctx context.Context, path string) (*Info, error) {
+	out, err := run(ctx, ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+			Duration  string `json:"duration"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			Size     string `json:"size"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("ffprobe: невалидный JSON: %w", err)
+	}
+
+	info := &Info{Probe: string(out)}
+	for _, s := range probe.Streams {
+		switch {
+		case s.CodecType == "audio":
+			info.HasAudio = true
+		case s.CodecType == "video" && info.Width == 0:
+			info.Width, info.Height, info.Codec = s.Width, s.Height, s.CodecName
+			info.Duration, _ = strconv.ParseFloat(s.Duration, 64)
+		}
+	}
+	if info.Width == 0 || info.Height == 0 {
+		return nil, fmt.Errorf("ffprobe: в %s нет видеопотока", path)
+	}
+	if d, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil && d > 0 {
+		info.Duration = d
+	}
+	info.Size, _ = strconv.ParseInt(probe.Format.Size, 10, 64)
+	if info.Size == 0 {
+		if st, err := os.Stat(path); err == nil {
+			info.Size = st.Size()
+		}
+	}
+	return info, nil
+}
+
+// Thumbnail сохраняет кадр на отметке at секунд как JPEG не больше 320×320 — требование Telegram к превью
+func Thumbnail(ctx context.Context, path string, at float64) (string, error) {
+	thumb := strings.TrimSuffix(path, ".mp4") + ".jpg"
+	_, err := run(ctx, ffmpegPath,
+		"-y", "-v", "error",
+		"-ss", strconv.FormatFloat(at, 'f', 2, 64),
+		"-i", path,
+		"-frames:v", "1",
+		"-vf", "scale=320:320:force_original_aspect_ratio=decrease",
+		"-q:v", "5",
+		thumb,
+	)
+	if err != nil {
+		return "", err
+	}
+	return thumb, nil
+}
+
+// LastFrame сохраняет последний кадр видео в JPEG рядом с ним (*_last.jpg)
+func LastFrame(ctx context.Context, path string) (string, error) {
+	frame := strings.TrimSuffix(path, ".mp4") + "_last.jpg"
+	// -update 1 перезаписывает файл каждым кадром последней секунды — остаётся самый последний
+	_, err := run(ctx, ffmpegPath,
+		"-y", "-v", "error",
+		"-sseof", "-1",
+		"-i", path,
+		"-update", "1",
+		"-q:v", "2",
+		frame,
+	)
+	if err != nil {
+		return "", err
+	}
+	return frame, nil
+}
+
+// Concat склеивает ролики встык в out с перекодированием (у сегментов могут отличаться
+// параметры после Transcode). Звук сохраняется, только если он есть во всех роликах.
+func Concat(ctx context.Context, out string, inputs ...string) error {
+	if len(inputs) < 2 {
+		return fmt.Errorf("ffmpeg: для склейки нужно минимум два ролика")
+	}
+
+	var first *Info
+	withAudio := true
+	for i, in := range inputs {
+		info, err := Probe(ctx, in)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			first = info
+		}
+		withAudio = withAudio && info.HasAudio
+	}
+
+	args := []string{"-y", "-v", "error"}
+	var filter strings.Builder
+	for i, in := range inputs {
+		args = append(args, "-i", in)
+		// приводим все сегменты к размеру первого, иначе concat не соберётся
+		fmt.Fprintf(&filter, "[%d:v]scale=%d:%d,setsar=1[v%d];", i, first.Width, first.Height, i)
+	}
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[v%d]", i)
+		if withAudio {
+			fmt.Fprintf(&filter, "[%d:a]", i)
+		}
+	}
+	audio := 0
+	if withAudio {
+		audio = 1
+	}
+	fmt.Fprintf(&filter, "concat=n=%d:v=1:a=%d[v]", len(inputs), audio)
+	if withAudio {
+		filter.WriteString("[a]")
+	}
+
+	args = append(args, "-filter_complex", filter.String(), "-map", "[v]")
+	if withAudio {
+		args = append(args, "-map", "[a]", "-c:a", "aac", "-b:a", "128k")
+	}
+	args = append(args,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+		"-movflags", "+faststart",
+		out,
+	)
+
+	_, err := run(ctx, ffmpegPath, args...)
+	return err
+}
+
+// Crossfade склеивает ролики с плавным переходом fade секунд (xfade / acrossfade).
+// Как и в Concat, звук остаётся, только если он есть во всех роликах.
+func Crossfade(ctx context.Context, out string, fade float64, inputs ...string) error {
+	if len(inputs) < 2 {
+		return fmt.Errorf("ffmpeg: для склейки нужно минимум два ролика")
+	}
+
+	infos := make([]*Info, len(inputs))
+	withAudio := true
+	for i, in := range inputs {
+		info, err := Probe(ctx, in)
+		if err != nil {
+			return err
+		}
+		if info.Duration <= fade {
+			return fmt.Errorf("ffmpeg: ролик %s короче перехода (%.1f с)", in, fade)
+		}
+		infos[i] = info
+		withAudio = withAudio && info.HasAudio
+	}
+
+	args := []string{"-y", "-v", "error"}
+	var filter strings.Builder
+	for i, in := range inputs {
+		args = append(args, "-i", in)
+		// xfade требует одинаковых размеров, частоты кадров и формата пикселей
+		fmt.Fprintf(&filter, "[%d:v]scale=%d:%d,setsar=1,fps=24,format=yuv420p[v%d];",
+			i, infos[0].Width, infos[0].Height, i)
+	}
+
+	// переход i-го стыка начинается за fade секунд до конца уже склеенной части
+	offset := 0.0
+	video, audio := "[v0]", "[0:a]"
+	for i := 1; i < len(inputs); i++ {
+		offset += infos[i-1].Duration - fade
+		fmt.Fprintf(&filter, "%s[v%d]xfade=transition=fade:duration=%.3f:offset=%.3f[x%d];", video, i, fade, offset, i)
+		video = fmt.Sprintf("[x%d]", i)
+		if withAudio {
+			fmt.Fprintf(&filter, "%s[%d:a]acrossfade=d=%.3f[a%d];", audio, i, fade, i)
+			audio = fmt.Sprintf("[a%d]", i)
+		}
+	}
+
+	args = append(args, "-filter_complex", strings.TrimSuffix(filter.String(), ";"), "-map", video)
+	if withAudio {
+		args = append(args, "-map", audio, "-c:a", "aac", "-b:a", "128k")
+	}
+	args = append(args,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+		"-movflags", "+faststart",
+		out,
+	)
+
+	_, err := run(ctx, ffmpegPath, args...)
+	return err
+}
+
+// Transcode пережимает видео на месте так, чтобы оно уложилось в limit байт,
+// и переносит moov-атом в начало файла (faststart) для потокового воспроизведения
+func Transcode(ctx context.Context, path string, limit int64, duration float64) error {
+	if duration <= 0 {
+		return fmt.Errorf("ffmpeg: неизвестна длительность %s", path)
+	}
+
+	// 90% лимита на видео и звук, 128 кбит/с на звук
+	const audioBitrate = 128_000
+	videoBitrate := int64(float64(limit)*8*0.9/duration) - audioBitrate
+	if videoBitrate < 100_000 {
+		videoBitrate = 100_000
+	}
+	rate := strconv.FormatInt(videoBitrate, 10)
+
+	tmp := strings.TrimSuffix(path, ".mp4") + ".tmp.mp4"
+	_, err := run(ctx, ffmpegPath,
+		"-y", "-v", "error",
+		"-i", path,
+		"-c:v", "libx264", "-preset", "veryfast",
+		"-b:v", rate, "-maxrate", rate, "-bufsize", strconv.FormatInt(videoBitrate*2, 10),
+		"-c:a", "aac", "-b:a", strconv.Itoa(audioBitrate),
+		"-movflags", "+faststart",
+		tmp,
+	)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,188 @@
+package mediastore
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+	"github.com/digkill/veo-telegram-bot/internal/repository"
+)
+
+// сколько строк user_logs обрабатывается за один запрос
+const sweepBatch = 100
+
+// DefaultRetention — сроки хранения по умолчанию; 0 — хранить всегда
+var DefaultRetention = map[string]time.Duration{
+	models.TierVideo:    30 * 24 * time.Hour,
+	models.TierSegment:  7 * 24 * time.Hour,
+	models.TierFavorite: 0,
+}
+
+// ParseRetention разбирает «video=30d,segment=7d,favorite=0»; неуказанные уровни берутся из DefaultRetention
+func ParseRetention(spec string) (map[string]time.Duration, error) {
+	retention := map[string]time.Duration{}
+	for tier, ttl := range DefaultRetention {
+		retention[tier] = ttl
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tier, value, ok := strings.Cut(part, "=")
+		if _, known := DefaultRetention[tier]; !ok || !known {
+			return nil, fmt.Errorf("ожидается уровень=срок (video, segment, favorite), получено %q", part)
+		}
+		ttl, err := parseTTL(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tier, err)
+		}
+		retention[tier] = ttl
+	}
+	return retention, nil
+}
+
+// parseTTL понимает дни («30d») и длительности Go («12h»)
+func parseTTL(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("неверный срок %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("неверный срок %q", s)
+	}
+	return d, nil
+}
+
+// StartSweeper запускает фоновую очистку раз в MEDIA_SWEEP_INTERVAL (по умолчанию час):
+// удаляет файлы с истёкшим сроком MEDIA_RETENTION и, если хранилище не локальное,
+// локальные копии старше MEDIA_CACHE_TTL, которые уже лежат в хранилище.
+func StartSweeper() {
+	retention, err := ParseRetention(os.Getenv("MEDIA_RETENTION"))
+	if err != nil {
+		log.Fatalf("❌ MEDIA_RETENTION: %v", err)
+	}
+	interval := envTTL("MEDIA_SWEEP_INTERVAL", time.Hour)
+	cacheTTL := envTTL("MEDIA_CACHE_TTL", 24*time.Hour)
+
+	go func() {
+		for {
+			ctx := context.Background()
+			Sweep(ctx, retention)
+			EvictCache(ctx, cacheTTL)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func envTTL(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := parseTTL(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
+// Sweep удаляет видео и превью с истёкшим сроком хранения и помечает строки user_logs как purged
+func Sweep(ctx context.Context, retention map[string]time.Duration) {
+	for tier, ttl := range retention {
+		if ttl <= 0 {
+			continue
+		}
+		for {
+			files, err := repository.ExpiredMedia(tier, ttl, sweepBatch)
+			if err != nil {
+				logger.LogError("media_sweep", map[string]interface{}{
+					"tier":  tier,
+					"error": err.Error(),
+				})
+				break
+			}
+
+			purged := 0
+			for _, f := range files {
+				if err := purge(ctx, f); err != nil {
+					logger.LogError("media_sweep", map[string]interface{}{
+						"log_id": f.LogID,
+						"file":   f.VideoPath,
+						"error":  err.Error(),
+					})
+					continue
+				}
+				purged++
+			}
+			if purged > 0 {
+				logger.LogInfo("media_purged", map[string]interface{}{
+					"tier":  tier,
+					"count": purged,
+				})
+			}
+			// неполная пачка — больше нечего; пачка без единого удаления — хранилище недоступно, ждём следующего прохода
+			if len(files) < sweepBatch || purged == 0 {
+				break
+			}
+		}
+	}
+}
+
+func purge(ctx context.Context, f models.MediaFile) error {
+	for _, path := range []string{f.VideoPath, f.ThumbPath} {
+		if path == "" {
+			continue
+		}
+		if err := Remove(ctx, path); err != nil {
+			return err
+		}
+	}
+	return repository.MarkMediaPurged(f.LogID)
+}
+
+// EvictCache удаляет из WorkDir локальные копии старше ttl, которые уже есть в удалённом хранилище;
+// при необходимости Localize скачает их обратно
+func EvictCache(ctx context.Context, ttl time.Duration) {
+	if local, ok := Default.(*LocalStore); ok && local.samePath(WorkDir, ".") {
+		return
+	}
+
+	evicted := 0
+	cutoff := time.Now().Add(-ttl)
+	_ = filepath.WalkDir(WorkDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".part") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		key, ok := Key(path)
+		if !ok {
+			return nil
+		}
+		if stored, err := Default.Exists(ctx, key); err != nil || !stored {
+			return nil
+		}
+		if err := os.Remove(path); err == nil {
+			evicted++
+		}
+		return nil
+	})
+	if evicted > 0 {
+		logger.LogInfo("media_cache_evicted", map[string]interface{}{
+			"store": Default.Name(),
+			"count": evicted,
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package mediastore хранит готовые видео и превью: на локальном диске или в S3-совместимом хранилище.
+//
+// Файлы по-прежнему создаются в рабочем каталоге WorkDir (ffmpeg и отправка в Telegram работают с локальными
+// путями), а ключ объекта — путь относительно WorkDir: storage/media/42/video_1.mp4 → 42/video_1.mp4.
+// Persist кладёт рабочий файл в хранилище, Localize возвращает его на диск, если локальной копии уже нет.
+package mediastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+)
+
+// WorkDir — рабочий каталог медиафайлов
+const WorkDir = "storage/media"
+
+// ErrNotFound — объекта нет в хранилище
+var ErrNotFound = errors.New("mediastore: объект не найден")
+
+// Store — хранилище медиафайлов по ключам
+type Store interface {
+	Name() string
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Default — хранилище из MEDIA_STORE (local по умолчанию)
+var Default = MustNew(os.Getenv("MEDIA_STORE"))
+
+// New создаёт хранилище по имени: local (MEDIA_LOCAL_ROOT, по умолчанию WorkDir) или s3 (переменные S3_*)
+func New(kind string) (Store, error) {
+	switch kind {
+	case "", "local":
+		root := os.Getenv("MEDIA_LOCAL_ROOT")
+		if root == "" {
+			root = WorkDir
+		}
+		return &LocalStore{Root: root}, nil
+	case "s3":
+		return NewS3FromEnv()
+	default:
+		return nil, fmt.Errorf("mediastore: неизвестное хранилище %q (ожидается local или s3)", kind)
+	}
+}
+
+func MustNew(kind string) Store {
+	store, err := New(kind)
+	if err != nil {
+		log.Fatalf("❌ MEDIA_STORE: %v", err)
+	}
+	return store
+}
+
+// Key — ключ объекта для рабочего файла; false, если файл лежит вне WorkDir
+func Key(path string) (string, bool) {
+	rel, err := filepath.Rel(WorkDir, filepath.Clean(path))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Persist сохраняет рабочие файлы в хранилище. Ошибки только логируются: локальная копия остаётся,
+// а перенести её можно позже командой cmd/mediamigrate.
+func Persist(ctx context.Context, paths ...string) {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := persist(ctx, Default, path); err != nil {
+			logger.LogError("media_persist", map[string]interface{}{
+				"store": Default.Name(),
+				"file":  path,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+func persist(ctx context.Context, store Store, path string) error {
+	key, ok := Key(path)
+	if !ok {
+		return fmt.Errorf("файл вне %s", WorkDir)
+	}
+	// локальное хранилище поверх рабочего каталога — файл уже на месте
+	if local, ok := store.(*LocalStore); ok && local.samePath(path, key) {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, f, st.Size())
+}
+
+// Localize возвращает рабочий файл на диск из хранилища, если локальной копии нет
+func Localize(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	key, ok := Key(path)
+	if !ok {
+		return fmt.Errorf("mediastore: файл %s не найден", path)
+	}
+	return download(ctx, Default, key, path)
+}
+
+func download(ctx context.Context, store Store, key, path string) error {
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Remove удаляет файл из хранилища и рабочую копию; отсутствие файла ошибкой не считается
+func Remove(ctx context.Context, path string) error {
+	if key, ok := Key(path); ok {
+		if err := Default.Delete(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Copy переносит объект между хранилищами (для cmd/mediamigrate)
+func Copy(ctx context.Context, from, to Store, key string) error {
+	body, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// размер нужен S3 для Content-Length — буферизуем через временный файл
+	tmp, err := os.CreateTemp("", "mediastore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return to.Put(ctx, key, tmp, size)
+}
+
+// LocalStore — файлы в каталоге Root
+type LocalStore struct {
+	Root string
+}
+
+func (s *LocalStore) Name() string { return "local" }
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) samePath(path, key string) bool {
+	a, err1 := filepath.Abs(path)
+	b, err2 := filepath.Abs(s.path(key))
+	return err1 == nil && err2 == nil && a == b
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+// Package metrics публикует счётчики бота через expvar и отдаёт их операторам по HTTP (/debug/vars)
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"os"
+
+	"github.com/digkill/veo-telegram-bot/internal/logger"
+)
+
+// Func публикует значение, которое вычисляется при каждом запросе /debug/vars
+func Func(name string, f func() interface{}) {
+	expvar.Publish(name, expvar.Func(f))
+}
+
+// Serve запускает HTTP-сервер метрик на METRICS_ADDR (например :9090); без переменной ничего не делает
+func Serve() {
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		return
+	}
+	go func() {
+		// expvar сам регистрирует /debug/vars в http.DefaultServeMux
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			logger.LogError("metrics", map[string]interface{}{
+				"addr":  addr,
+				"error": err.Error(),
+			})
+		}
+	}()
+}
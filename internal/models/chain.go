@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// VideoChain — видео, удлинённое продолжениями: каждый сегмент генерируется
+// от последнего кадра предыдущего и приклеивается к общему файлу
+type VideoChain struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	ChatID    int64     `db:"chat_id"`
+	LogID     int64     `db:"log_id"`     // строка user_logs с текущим склеенным видео
+	VideoPath string    `db:"video_path"` // текущее склеенное видео
+	Segments  int       `db:"segments"`   // включая исходный ролик
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Статусы задачи генерации: queued → running → succeeded → delivered,
+// либо терминальные failed / timeout / cancelled
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDelivered = "delivered"
+	JobFailed    = "failed"
+	JobTimeout   = "timeout"
+	JobCancelled = "cancelled"
+)
+
+type GenerationJob struct {
+	ID                int64      `db:"id"`
+	UserID            int64      `db:"user_id"`
+	ChatID            int64      `db:"chat_id"`
+	Prompt            string     `db:"prompt"`
+	Params            string     `db:"params"` // JSON с параметрами генерации
+	ImageBase64       string     `db:"image_base64"`
+	LastFrameBase64   string     `db:"last_frame_base64"` // конечный кадр для интерполяции, base64
+	ModelID           string     `db:"model_id"`
+	ChainID           int64      `db:"chain_id"`    // 0 — обычная генерация, иначе продолжение цепочки
+	StoryID           int64      `db:"story_id"`    // 0 — не сцена раскадровки
+	SceneIndex        int        `db:"scene_index"` // номер сцены в раскадровке, с нуля
+	OperationName     string     `db:"operation_name"`
+	Region            string     `db:"region"`              // «project/location», в котором запущена операция
+	ProgressMessageID int        `db:"progress_message_id"` // сообщение с ходом генерации; 0 — не отправлялось
+	Status            string     `db:"status"`
+	Attempts          int        `db:"attempts"`
+	Credits           int        `db:"credits"`    // списанные за задачу кредиты
+	VideoPath         string     `db:"video_path"` // первое видео; полный список — в Videos
+	Videos            []JobVideo `db:"videos"`     // хранится как JSON
+	ErrorMessage      string     `db:"error_message"`
+	CreatedAt         time.Time  `db:"created_at"`
+	StartedAt         *time.Time `db:"started_at"` // запуск операции Vertex AI; nil, пока задача в очереди
+	UpdatedAt         time.Time  `db:"updated_at"`
+	FinishedAt        *time.Time `db:"finished_at"`
+}
+
+// RunningSince — момент запуска операции; у задач, запущенных до колонки started_at, — момент создания
+func (j *GenerationJob) RunningSince() time.Time {
+	if j.StartedAt != nil {
+		return *j.StartedAt
+	}
+	return j.CreatedAt
+}
+
+// JobVideo — один вариант результата и его строка в user_logs
+type JobVideo struct {
+	Path     string  `json:"path"`
+	LogID    int64   `json:"log_id"`
+	Thumb    string  `json:"thumb,omitempty"` // превью от ffmpeg; пусто, если постобработка не удалась
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	FileID   string  `json:"file_id,omitempty"` // file_id в Telegram после отправки — для повторной отправки без загрузки
+}
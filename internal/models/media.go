@@ -0,0 +1,16 @@
+package models
+
+// Уровни хранения медиафайлов: у каждого свой срок (MEDIA_RETENTION).
+// Избранное определяется флагом user_logs.is_favorite и важнее media_tier.
+const (
+	TierVideo    = "video"    // видео, отправленное пользователю
+	TierSegment  = "segment"  // промежуточный файл: сцена раскадровки или сегмент продления
+	TierFavorite = "favorite" // вариант, отмеченный ⭐
+)
+
+// MediaFile — строка user_logs с файлами видео и превью
+type MediaFile struct {
+	LogID     int64
+	VideoPath string
+	ThumbPath string
+}
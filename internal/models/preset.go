@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Preset — стилевой пресет: текст вокруг промта и параметры по умолчанию.
+// Пресеты заводят операторы командой /preset_add, пользователь выбирает их кнопкой перед подтверждением.
+type Preset struct {
+	ID             int64     `db:"id"`
+	Name           string    `db:"name"`  // короткое имя для команд оператора, например cinematic
+	Title          string    `db:"title"` // название на кнопке, например «🎬 Кино»
+	PromptPrefix   string    `db:"prompt_prefix"`
+	PromptSuffix   string    `db:"prompt_suffix"`
+	AspectRatio    string    `db:"aspect_ratio"`    // пусто — формат из тегов или по умолчанию
+	NegativePrompt string    `db:"negative_prompt"` // пусто — без негативного промта
+	ModelID        string    `db:"model_id"`        // пусто — модель пользователя
+	Active         bool      `db:"active"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// Apply оборачивает промт префиксом и суффиксом пресета
+func (p *Preset) Apply(cleanPrompt string) string {
+	var parts []string
+	for _, part := range []string{p.PromptPrefix, cleanPrompt, p.PromptSuffix} {
+		if part = strings.Trim(part, " ,"); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PresetStats — использование пресета для аналитики
+type PresetStats struct {
+	PresetID int64
+	Uses     int // генераций с пресетом за всё время
+	Recent   int // за последние 7 дней
+	Users    int // разных пользователей
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CachedResult — доставленный результат детерминированного запроса (с #seed),
+// который можно прислать повторно по file_id без новой генерации
+type CachedResult struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	Key       string     `db:"cache_key"` // sha256 нормализованного промта, картинок, модели и параметров
+	JobID     int64      `db:"job_id"`
+	ModelID   string     `db:"model_id"`
+	Credits   int        `db:"credits"` // сколько стоила бы повторная генерация
+	Videos    []JobVideo `db:"videos"`  // хранится как JSON, у каждого видео есть FileID
+	CreatedAt time.Time  `db:"created_at"`
+}
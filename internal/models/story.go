@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Статусы раскадровки: сцены генерируются (running), затем склеиваются (done);
+// failed — раскадровку не удалось запустить
+const (
+	StoryRunning = "running"
+	StoryDone    = "done"
+	StoryFailed  = "failed"
+)
+
+// Story — раскадровка из нескольких сцен; каждая сцена — отдельная задача генерации
+type Story struct {
+	ID                int64     `db:"id"`
+	UserID            int64     `db:"user_id"`
+	ChatID            int64     `db:"chat_id"`
+	ModelID           string    `db:"model_id"`
+	Scenes            int       `db:"scenes"`
+	Crossfade         float64   `db:"crossfade"` // секунды; 0 — склейка встык
+	Status            string    `db:"status"`
+	ProgressMessageID int       `db:"progress_message_id"` // сообщение с прогрессом по сценам
+	VideoPath         string    `db:"video_path"`
+	LogID             int64     `db:"log_id"`
+	CreatedAt         time.Time `db:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at"`
+}
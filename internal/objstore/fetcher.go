@@ -0,0 +1,98 @@
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Fetcher скачивает объект по ссылке вида gs://bucket/path
+type Fetcher interface {
+	Fetch(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// TokenFunc возвращает OAuth access token для Cloud Storage
+type TokenFunc func(ctx context.Context) (string, error)
+
+// ParseGCSURI разбирает gs://bucket/object на бакет и имя объекта
+func ParseGCSURI(uri string) (bucket, object string, err error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	if !ok {
+		return "", "", fmt.Errorf("ожидается ссылка gs://, получено %q", uri)
+	}
+	bucket, object, ok = strings.Cut(rest, "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("в ссылке %q нет бакета или объекта", uri)
+	}
+	return bucket, object, nil
+}
+
+// GCSFetcher качает объекты через JSON API Cloud Storage (или совместимую заглушку по BaseURL)
+type GCSFetcher struct {
+	BaseURL    string // по умолчанию https://storage.googleapis.com
+	HTTPClient *http.Client
+	Token      TokenFunc
+}
+
+func NewGCSFetcher(baseURL string, token TokenFunc) *GCSFetcher {
+	return &GCSFetcher{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		Token:      token,
+	}
+}
+
+func (f *GCSFetcher) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) {
+	bucket, object, err := ParseGCSURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		f.BaseURL, url.PathEscape(bucket), url.PathEscape(object))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Token != nil {
+		token, err := f.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("gcs: не удалось получить access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("gcs: HTTP %d для %s: %s", resp.StatusCode, uri, body)
+	}
+	return resp.Body, nil
+}
+
+// LocalFetcher читает gs://bucket/object из Root/bucket/object — заглушка для локальной разработки
+type LocalFetcher struct {
+	Root string
+}
+
+func (f *LocalFetcher) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) {
+	bucket, object, err := ParseGCSURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(f.Root, bucket, filepath.FromSlash(object))
+	if !strings.HasPrefix(path, filepath.Clean(f.Root)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("ссылка %q выходит за пределы %s", uri, f.Root)
+	}
+	return os.Open(path)
+}
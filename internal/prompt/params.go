@@ -0,0 +1,199 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultAspectRatio = "16:9"
+	MaxSampleCount     = 4
+)
+
+// GenerationParams — параметры генерации, заданные тегами в тексте промта.
+// Хранится в задаче как JSON; пустые поля означают значение модели по умолчанию.
+// Здесь проверяются только общие границы — возможности конкретной модели проверяет бот по реестру.
+type GenerationParams struct {
+	AspectRatio     string  `json:"aspect_ratio"`
+	SampleCount     int     `json:"sample_count,omitempty"`
+	DurationSeconds int     `json:"duration_seconds,omitempty"`
+	Seed            *uint32 `json:"seed,omitempty"`
+	GenerateAudio   *bool   `json:"generate_audio,omitempty"`
+	Resolution      string  `json:"resolution,omitempty"`
+	NegativePrompt  string  `json:"negative_prompt,omitempty"`
+	Fit             string  `json:"fit,omitempty"` // crop | pad — как вписать картинку в кадр
+}
+
+// Samples — число вариантов; у задач, созданных до #xN, поле пустое
+func (p GenerationParams) Samples() int {
+	if p.SampleCount < 1 {
+		return 1
+	}
+	return p.SampleCount
+}
+
+// Audio — генерировать ли звуковую дорожку (по умолчанию да)
+func (p GenerationParams) Audio() bool {
+	return p.GenerateAudio == nil || *p.GenerateAudio
+}
+
+// ParseError — список проблем с тегами, который показывается пользователю целиком
+type ParseError struct {
+	Problems []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+var (
+	aspectRe     = regexp.MustCompile(`^(\d+):(\d+)$`)
+	durationRe   = regexp.MustCompile(`^(\d+)s$`)
+	samplesRe    = regexp.MustCompile(`^x(\d+)$`)
+	resolutionRe = regexp.MustCompile(`^(\d+)p$`)
+
+	supportedAspectRatios = map[string]bool{"16:9": true, "9:16": true}
+	supportedResolutions  = map[string]bool{"720p": true, "1080p": true}
+)
+
+// SupportedAspectRatio — поддерживается ли формат кадра (без #), например для пресетов
+func SupportedAspectRatio(ratio string) bool {
+	return supportedAspectRatios[ratio]
+}
+
+const (
+	minDuration = 4
+	maxDuration = 8
+)
+
+// Parse отделяет теги (#16:9, #8s, #seed=42, #noaudio, #1080p, #x2, #crop, #pad, #neg:…) от текста промта.
+// Хештеги, не похожие на параметры (например #кот), остаются в промте.
+// Все ошибки собираются в один *ParseError.
+func Parse(text string) (string, GenerationParams, error) {
+	return ParseWithDefaults(text, GenerationParams{})
+}
+
+// ParseWithDefaults — Parse, где параметры без тегов берутся из defaults (например, из стилевого пресета).
+// Теги в тексте всегда важнее значений по умолчанию.
+func ParseWithDefaults(text string, defaults GenerationParams) (string, GenerationParams, error) {
+	params := defaults
+	if params.AspectRatio == "" {
+		params.AspectRatio = DefaultAspectRatio
+	}
+	var problems []string
+	seen := map[string]string{}
+
+	// set запоминает, какой тег задал параметр, чтобы сообщить о противоречиях
+	set := func(key, tag string) bool {
+		if prev, ok := seen[key]; ok && !strings.EqualFold(prev, tag) {
+			problems = append(problems, fmt.Sprintf("#%s и #%s противоречат друг другу", prev, tag))
+			return false
+		}
+		seen[key] = tag
+		return true
+	}
+
+	var clean []string
+	for _, line := range strings.Split(text, "\n") {
+		var kept []string
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if !strings.HasPrefix(field, "#") || len(field) == 1 {
+				kept = append(kept, field)
+				continue
+			}
+			tag := field[1:]
+			lower := strings.ToLower(tag)
+
+			switch {
+			case strings.HasPrefix(lower, "neg:"):
+				// негативный промт тянется до следующего тега или конца строки
+				words := []string{tag[len("neg:"):]}
+				for i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "#") {
+					i++
+					words = append(words, fields[i])
+				}
+				neg := strings.TrimSpace(strings.Join(words, " "))
+				if neg == "" {
+					problems = append(problems, "#neg: пустой негативный промт — пример: #neg:размытый текст")
+					continue
+				}
+				if set("neg", "neg:"+neg) {
+					params.NegativePrompt = neg
+				}
+
+			case aspectRe.MatchString(lower):
+				if !supportedAspectRatios[lower] {
+					problems = append(problems, fmt.Sprintf("#%s: поддерживаются только #16:9 и #9:16", tag))
+					continue
+				}
+				if set("aspect", lower) {
+					params.AspectRatio = lower
+				}
+
+			case durationRe.MatchString(lower):
+				n, _ := strconv.Atoi(durationRe.FindStringSubmatch(lower)[1])
+				if n < minDuration || n > maxDuration {
+					problems = append(problems, fmt.Sprintf("#%s: длительность должна быть от %d до %d секунд", tag, minDuration, maxDuration))
+					continue
+				}
+				if set("duration", lower) {
+					params.DurationSeconds = n
+				}
+
+			case samplesRe.MatchString(lower):
+				n, _ := strconv.Atoi(samplesRe.FindStringSubmatch(lower)[1])
+				if n < 1 || n > MaxSampleCount {
+					problems = append(problems, fmt.Sprintf("#%s: можно заказать от 1 до %d вариантов", tag, MaxSampleCount))
+					continue
+				}
+				if set("samples", lower) {
+					params.SampleCount = n
+				}
+
+			case strings.HasPrefix(lower, "seed="):
+				n, err := strconv.ParseUint(lower[len("seed="):], 10, 32)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("#%s: seed должен быть целым числом от 0 до 4294967295", tag))
+					continue
+				}
+				if set("seed", lower) {
+					seed := uint32(n)
+					params.Seed = &seed
+				}
+
+			case lower == "noaudio" || lower == "audio":
+				if set("audio", lower) {
+					audio := lower == "audio"
+					params.GenerateAudio = &audio
+				}
+
+			case lower == "crop" || lower == "pad":
+				if set("fit", lower) {
+					params.Fit = lower
+				}
+
+			case resolutionRe.MatchString(lower):
+				if !supportedResolutions[lower] {
+					problems = append(problems, fmt.Sprintf("#%s: поддерживаются #720p и #1080p", tag))
+					continue
+				}
+				if set("resolution", lower) {
+					params.Resolution = lower
+				}
+
+			default:
+				kept = append(kept, field)
+			}
+		}
+		clean = append(clean, strings.Join(kept, " "))
+	}
+
+	if len(problems) > 0 {
+		return "", params, &ParseError{Problems: problems}
+	}
+	return strings.TrimSpace(strings.Join(clean, "\n")), params, nil
+}
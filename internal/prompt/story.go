@@ -0,0 +1,172 @@
+package prompt
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MaxScenes        = 8
+	DefaultCrossfade = 0.5 // секунды, для #fade без значения
+	maxCrossfade     = 2.0
+)
+
+// Scene — одна сцена раскадровки с уже применёнными общими тегами
+type Scene struct {
+	Prompt string
+	Params GenerationParams
+}
+
+// Story — раскадровка: сцены генерируются отдельно и склеиваются в одно видео
+type Story struct {
+	Scenes    []Scene
+	Crossfade float64 // длительность перехода между сценами; 0 — встык
+}
+
+var (
+	sceneNumberRe = regexp.MustCompile(`^\s*(\d+)[.)]\s+(.*)$`)
+	fadeRe        = regexp.MustCompile(`^#(?:cross)?fade(?:=(\d+(?:\.\d+)?)s?)?$`)
+)
+
+// ParseStory разбирает сценарий: сцены разделяются строкой «---» или нумеруются («1. …», «2) …»).
+// Строка с одними тегами перед первой сценой задаёт общие параметры (#9:16, #seed=42, #fade=1).
+// Формат и seed у всех сцен общие; если seed не задан, выбирается случайный.
+func ParseStory(text string) (*Story, error) {
+	header, blocks := splitScenes(text)
+	story := &Story{}
+	var problems []string
+
+	var shared []string
+	inNeg := false
+	for _, field := range strings.Fields(header) {
+		if !strings.HasPrefix(field, "#") {
+			// слова заголовка (название ролика) в сцены не попадают, кроме текста #neg:
+			if inNeg {
+				shared = append(shared, field)
+			}
+			continue
+		}
+		inNeg = strings.HasPrefix(strings.ToLower(field), "#neg:")
+		if m := fadeRe.FindStringSubmatch(strings.ToLower(field)); m != nil {
+			story.Crossfade = DefaultCrossfade
+			if m[1] != "" {
+				story.Crossfade, _ = strconv.ParseFloat(m[1], 64)
+			}
+			if story.Crossfade <= 0 || story.Crossfade > maxCrossfade {
+				problems = append(problems, fmt.Sprintf("%s: переход должен быть от 0 до %.0f секунд", field, maxCrossfade))
+			}
+			continue
+		}
+		shared = append(shared, field)
+	}
+
+	_, sharedParams, err := Parse(strings.Join(shared, " "))
+	if err != nil {
+		return nil, err
+	}
+	if sharedParams.SampleCount > 0 {
+		problems = append(problems, "в раскадровке каждая сцена генерируется в одном варианте — убери тег #x")
+	}
+	if sharedParams.Seed == nil {
+		// общий seed делает сцены похожими по стилю
+		shared = append(shared, fmt.Sprintf("#seed=%d", rand.Uint32()))
+	}
+
+	switch {
+	case len(blocks) < 2:
+		problems = append(problems, "в сценарии нужно минимум 2 сцены — раздели их строкой --- или пронумеруй")
+	case len(blocks) > MaxScenes:
+		problems = append(problems, fmt.Sprintf("в сценарии не больше %d сцен", MaxScenes))
+	}
+
+	for i, block := range blocks {
+		clean, params, err := Parse(strings.Join(shared, " ") + "\n" + block)
+		if err != nil {
+			for _, p := range err.(*ParseError).Problems {
+				problems = append(problems, fmt.Sprintf("сцена %d: %s", i+1, p))
+			}
+			continue
+		}
+		switch {
+		case clean == "":
+			problems = append(problems, fmt.Sprintf("сцена %d: пустое описание", i+1))
+		case params.SampleCount > 1:
+			problems = append(problems, fmt.Sprintf("сцена %d: в раскадровке нельзя заказать несколько вариантов", i+1))
+		case len(story.Scenes) > 0 && params.AspectRatio != story.Scenes[0].Params.AspectRatio:
+			problems = append(problems, fmt.Sprintf("сцена %d: формат %s отличается от первой сцены — задай общий формат в первой строке",
+				i+1, params.AspectRatio))
+		}
+		story.Scenes = append(story.Scenes, Scene{Prompt: clean, Params: params})
+	}
+
+	if len(problems) > 0 {
+		return nil, &ParseError{Problems: problems}
+	}
+	return story, nil
+}
+
+// splitScenes делит текст на заголовок с общими тегами и тексты сцен
+func splitScenes(text string) (header string, scenes []string) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	hasSeparator := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			hasSeparator = true
+			break
+		}
+	}
+
+	if hasSeparator {
+		var block []string
+		flush := func() {
+			if s := strings.TrimSpace(strings.Join(block, "\n")); s != "" {
+				scenes = append(scenes, s)
+			}
+			block = nil
+		}
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "---" {
+				flush()
+				continue
+			}
+			block = append(block, line)
+		}
+		flush()
+
+		// первый блок из одних тегов — это общие параметры, а не сцена
+		if len(scenes) > 0 && onlyTags(scenes[0]) {
+			header, scenes = scenes[0], scenes[1:]
+		}
+		return header, scenes
+	}
+
+	var headerLines []string
+	for _, line := range lines {
+		if m := sceneNumberRe.FindStringSubmatch(line); m != nil {
+			scenes = append(scenes, strings.TrimSpace(m[2]))
+			continue
+		}
+		if len(scenes) == 0 {
+			headerLines = append(headerLines, line)
+			continue
+		}
+		// продолжение текущей сцены на следующей строке
+		if s := strings.TrimSpace(line); s != "" {
+			scenes[len(scenes)-1] += "\n" + s
+		}
+	}
+	return strings.Join(headerLines, "\n"), scenes
+}
+
+func onlyTags(text string) bool {
+	for _, field := range strings.Fields(text) {
+		if !strings.HasPrefix(field, "#") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+var (
+	// ErrChainBusy — цепочка уже продлевается: второе продолжение склеилось бы с тем же видео и затёрло первое
+	ErrChainBusy = errors.New("цепочка уже продлевается")
+	// ErrChainFull — достигнут лимит продлений
+	ErrChainFull = errors.New("достигнут лимит продлений")
+	// ErrChainChanged — цепочку продлили, пока генерировался сегмент
+	ErrChainChanged = errors.New("цепочка изменилась во время генерации")
+)
+
+const chainColumns = `id, user_id, chat_id, log_id, video_path, segments, created_at, updated_at`
+
+// CreateChain начинает цепочку продолжений от уже доставленного видео. segments — сколько сегментов
+// уже в нём: 1 для обычного видео, больше — если это промежуточная склейка другой цепочки.
+func CreateChain(userID, chatID, logID int64, videoPath string, segments int) (*models.VideoChain, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO video_chains (user_id, chat_id, log_id, video_path, segments)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, chatID, logID, videoPath, segments,
+	)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO chain_segments (chain_id, job_id, log_id, video_path, position, result_log_id)
+		VALUES (?, NULL, ?, ?, ?, ?)`,
+		chainID, logID, videoPath, segments, logID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetChain(chainID)
+}
+
+func GetChain(chainID int64) (*models.VideoChain, error) {
+	return scanChain(db.DB.QueryRow(`SELECT `+chainColumns+` FROM video_chains WHERE id = ?`, chainID))
+}
+
+// GetChainBySegmentLog ищет цепочку, в которой видео logID было результатом склейки, и его позицию
+// (сколько сегментов в нём). Позиция меньше chain.Segments — видео с тех пор продлили ещё раз.
+// nil — видео не входит ни в одну цепочку.
+func GetChainBySegmentLog(userID, logID int64) (*models.VideoChain, int, error) {
+	var chain models.VideoChain
+	var position int
+	err := db.DB.QueryRow(`SELECT c.id, c.user_id, c.chat_id, c.log_id, c.video_path, c.segments, c.created_at, c.updated_at, s.position
+		FROM chain_segments s JOIN video_chains c ON c.id = s.chain_id
+		WHERE c.user_id = ? AND s.result_log_id = ? ORDER BY s.id DESC LIMIT 1`, userID, logID).Scan(
+		&chain.ID, &chain.UserID, &chain.ChatID, &chain.LogID, &chain.VideoPath,
+		&chain.Segments, &chain.CreatedAt, &chain.UpdatedAt, &position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return &chain, position, nil
+}
+
+// CreateChainJob создаёт задачу продолжения цепочки. Строка цепочки блокируется на время проверки,
+// поэтому из двух одновременных продолжений создаётся только одно.
+func CreateChainJob(job *models.GenerationJob, maxExtensions int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var segments int
+	if err := tx.QueryRow(`SELECT segments FROM video_chains WHERE id = ? FOR UPDATE`, job.ChainID).Scan(&segments); err != nil {
+		return err
+	}
+	if segments > maxExtensions {
+		return ErrChainFull
+	}
+
+	busy, err := chainBusy(tx, job.ChainID)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ErrChainBusy
+	}
+
+	if err := insertJob(tx, job); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChainBusy — у цепочки есть задача продолжения в очереди или в работе
+func ChainBusy(chainID int64) (bool, error) {
+	return chainBusy(db.DB, chainID)
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func chainBusy(q rowQuerier, chainID int64) (bool, error) {
+	var busy bool
+	err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM generation_jobs WHERE chain_id = ? AND status IN (?, ?))`,
+		chainID, models.JobQueued, models.JobRunning).Scan(&busy)
+	return busy, err
+}
+
+// ChainHasJob — сегмент задачи уже приклеен (например, до перезапуска бота)
+func ChainHasJob(chainID, jobID int64) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM chain_segments WHERE chain_id = ? AND job_id = ?)`,
+		chainID, jobID).Scan(&exists)
+	return exists, err
+}
+
+// AppendChainSegment записывает новый сегмент задачи и переключает цепочку на склеенное видео.
+// segments — сколько сегментов было в цепочке, когда её видео склеивалось с новым; если с тех пор
+// цепочку продлили, возвращается ErrChainChanged, чтобы не затереть чужое продолжение.
+func AppendChainSegment(chainID, jobID int64, segments int, segmentLogID int64, segmentPath string, resultLogID int64, resultPath string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current int
+	if err := tx.QueryRow(`SELECT segments FROM video_chains WHERE id = ? FOR UPDATE`, chainID).Scan(&current); err != nil {
+		return err
+	}
+	if current != segments {
+		return ErrChainChanged
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO chain_segments (chain_id, job_id, log_id, video_path, position, result_log_id)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		chainID, jobID, segmentLogID, segmentPath, segments+1, resultLogID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE video_chains SET log_id = ?, video_path = ?, segments = ? WHERE id = ?`,
+		resultLogID, resultPath, segments+1, chainID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetLogVideo возвращает путь к видео из user_logs, если строка принадлежит пользователю
+// и видео не удалено по сроку хранения
+func GetLogVideo(userID, logID int64) (string, error) {
+	var path sql.NullString
+	err := db.DB.QueryRow(`SELECT video_path FROM user_logs WHERE id = ? AND user_id = ? AND purged_at IS NULL`, logID, userID).Scan(&path)
+	if err != nil {
+		return "", err
+	}
+	if path.String == "" {
+		return "", sql.ErrNoRows
+	}
+	return path.String, nil
+}
+
+func scanChain(row rowScanner) (*models.VideoChain, error) {
+	var chain models.VideoChain
+	err := row.Scan(&chain.ID, &chain.UserID, &chain.ChatID, &chain.LogID, &chain.VideoPath,
+		&chain.Segments, &chain.CreatedAt, &chain.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
@@ -84,3 +84,9 @@ func CheckCredits(telegramID int64) (int, error) {
 
 	return credits, nil
 }
+
+// RefundCredits — вернуть кредиты за несостоявшуюся генерацию
+func RefundCredits(telegramID int64, amount int) error {
+	_, err := db.DB.Exec("UPDATE users SET credits = credits + ? WHERE telegram_id = ?", amount, telegramID)
+	return err
+}
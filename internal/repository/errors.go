@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/digkill/veo-telegram-bot/internal/db"
+)
+
+// LogGenerationError записывает ошибку или блокировку генерации в generation_errors
+func LogGenerationError(userID int64, prompt string, code int, supportCode string, message string) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO generation_errors (user_id, prompt, error_code, support_code, error_message)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, prompt, code, supportCode, message,
+	)
+	return err
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+const jobColumns = `id, user_id, chat_id, prompt, params, image_base64, last_frame_base64, model_id, chain_id, story_id, scene_index, operation_name, region,
+	progress_message_id, status, attempts, credits, video_path, videos, error_message, created_at, started_at, updated_at, finished_at`
+
+// CreateJob сохраняет новую задачу в статусе queued и проставляет ей ID
+func CreateJob(job *models.GenerationJob) error {
+	return insertJob(db.DB, job)
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertJob(ex execer, job *models.GenerationJob) error {
+	// время ставим сами: от него отсчитывается дедлайн опроса, и оно не должно зависеть от часового пояса MySQL
+	job.CreatedAt = time.Now()
+	res, err := ex.Exec(`
+		INSERT INTO generation_jobs (user_id, chat_id, prompt, params, image_base64, last_frame_base64, model_id, chain_id,
+			story_id, scene_index, status, credits, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.UserID, job.ChatID, job.Prompt, job.Params, job.ImageBase64, job.LastFrameBase64, job.ModelID, nullInt64(job.ChainID),
+		nullInt64(job.StoryID), job.SceneIndex, models.JobQueued, job.Credits, job.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	job.ID, err = res.LastInsertId()
+	job.Status = models.JobQueued
+	return err
+}
+
+// GetUnfinishedJobs возвращает задачи, которые нужно продолжить после перезапуска
+func GetUnfinishedJobs() ([]*models.GenerationJob, error) {
+	rows, err := db.DB.Query(`SELECT `+jobColumns+` FROM generation_jobs
+		WHERE status IN (?, ?, ?) ORDER BY id`,
+		models.JobQueued, models.JobRunning, models.JobSucceeded,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*models.GenerationJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func IncrementJobAttempts(jobID int64) error {
+	_, err := db.DB.Exec(`UPDATE generation_jobs SET attempts = attempts + 1 WHERE id = ?`, jobID)
+	return err
+}
+
+// SetJobOperation запоминает операцию Vertex AI и её регион и переводит задачу в running.
+// startedAt — момент запуска операции: от него считаются дедлайн опроса и длительность генерации.
+func SetJobOperation(jobID int64, operationName, region string, startedAt time.Time) error {
+	_, err := db.DB.Exec(`UPDATE generation_jobs SET operation_name = ?, region = ?, status = ?, started_at = ? WHERE id = ?`,
+		operationName, region, models.JobRunning, startedAt, jobID)
+	return err
+}
+
+// SetJobProgressMessage запоминает сообщение, в котором показывается ход генерации
+func SetJobProgressMessage(jobID int64, messageID int) error {
+	_, err := db.DB.Exec(`UPDATE generation_jobs SET progress_message_id = ? WHERE id = ?`, messageID, jobID)
+	return err
+}
+
+// AverageJobDuration — средняя длительность последних limit успешных генераций модели
+// (от запуска операции до сохранения видео) и число задач, по которым она посчитана
+func AverageJobDuration(modelID string, limit int) (time.Duration, int, error) {
+	var avg sql.NullFloat64
+	var n int
+	err := db.DB.QueryRow(`
+		SELECT AVG(d), COUNT(*) FROM (
+			SELECT TIMESTAMPDIFF(SECOND, started_at, finished_at) AS d FROM generation_jobs
+			WHERE model_id = ? AND status IN (?, ?) AND started_at IS NOT NULL AND finished_at IS NOT NULL
+			ORDER BY id DESC LIMIT ?
+		) recent`,
+		modelID, models.JobSucceeded, models.JobDelivered, limit,
+	).Scan(&avg, &n)
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(avg.Float64 * float64(time.Second)), n, nil
+}
+
+// CompleteJob сохраняет готовые видео; доставка отмечается отдельно
+func CompleteJob(jobID int64, videos []models.JobVideo) error {
+	videosJSON, err := json.Marshal(videos)
+	if err != nil {
+		return err
+	}
+	videoPath := ""
+	if len(videos) > 0 {
+		videoPath = videos[0].Path
+	}
+	_, err = db.DB.Exec(`UPDATE generation_jobs SET video_path = ?, videos = ?, status = ?, finished_at = ? WHERE id = ?`,
+		videoPath, string(videosJSON), models.JobSucceeded, time.Now(), jobID)
+	return err
+}
+
+func MarkJobDelivered(jobID int64) error {
+	_, err := db.DB.Exec(`UPDATE generation_jobs SET status = ? WHERE id = ?`, models.JobDelivered, jobID)
+	return err
+}
+
+// FailJob переводит задачу в терминальный статус (failed / timeout) с текстом ошибки
+func FailJob(jobID int64, status string, errMsg string) error {
+	_, err := db.DB.Exec(`UPDATE generation_jobs SET status = ?, error_message = ?, finished_at = ? WHERE id = ?`,
+		status, errMsg, time.Now(), jobID)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*models.GenerationJob, error) {
+	var job models.GenerationJob
+	var params, image, lastFrame, operation, region, videoPath, videos, errMsg sql.NullString
+	var chainID, storyID, progressMessageID sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.ChatID, &job.Prompt, &params, &image, &lastFrame, &job.ModelID, &chainID, &storyID, &job.SceneIndex, &operation, &region,
+		&progressMessageID, &job.Status, &job.Attempts, &job.Credits, &videoPath, &videos, &errMsg, &job.CreatedAt, &startedAt, &job.UpdatedAt, &finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Params = params.String
+	job.ImageBase64 = image.String
+	job.LastFrameBase64 = lastFrame.String
+	job.ChainID = chainID.Int64
+	job.StoryID = storyID.Int64
+	job.OperationName = operation.String
+	job.Region = region.String
+	job.ProgressMessageID = int(progressMessageID.Int64)
+	job.VideoPath = videoPath.String
+	if videos.String != "" {
+		if err := json.Unmarshal([]byte(videos.String), &job.Videos); err != nil {
+			return nil, err
+		}
+	} else if job.VideoPath != "" {
+		// задачи, завершённые до появления колонки videos
+		job.Videos = []models.JobVideo{{Path: job.VideoPath}}
+	}
+	job.ErrorMessage = errMsg.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// nullInt64 пишет 0 как NULL — для необязательных внешних ключей
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/media"
 )
 
 func LogAction(userID int64, actionType string, prompt string, success bool, videoPath string) {
@@ -11,3 +12,37 @@ func LogAction(userID int64, actionType string, prompt string, success bool, vid
 		userID, actionType, prompt, success, videoPath,
 	)
 }
+
+// LogGeneration записывает успешную генерацию и возвращает ID строки
+func LogGeneration(userID int64, prompt string, videoPath string) (int64, error) {
+	res, err := db.DB.Exec(`
+		INSERT INTO user_logs (user_id, action_type, prompt, success, video_path)
+		VALUES (?, 'generation', ?, 1, ?)`,
+		userID, prompt, videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// SetLogMedia сохраняет результаты ffprobe и путь к превью рядом со строкой генерации
+func SetLogMedia(logID int64, info *media.Info) error {
+	_, err := db.DB.Exec(`
+		UPDATE user_logs SET thumb_path = ?, width = ?, height = ?, duration = ?, probe = ?
+		WHERE id = ?`,
+		info.ThumbPath, info.Width, info.Height, info.Duration, info.Probe, logID,
+	)
+	return err
+}
+
+// SetFavorite отмечает вариант как любимый; false — если строка не принадлежит пользователю
+func SetFavorite(userID int64, logID int64) (bool, error) {
+	var owned bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_logs WHERE id = ? AND user_id = ?)`, logID, userID).Scan(&owned)
+	if err != nil || !owned {
+		return false, err
+	}
+	_, err = db.DB.Exec(`UPDATE user_logs SET is_favorite = 1 WHERE id = ?`, logID)
+	return err == nil, err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+// SetLogTier задаёт уровень хранения видео (models.TierVideo / TierSegment)
+func SetLogTier(logID int64, tier string) error {
+	_, err := db.DB.Exec(`UPDATE user_logs SET media_tier = ? WHERE id = ?`, tier, logID)
+	return err
+}
+
+// ExpiredMedia — файлы уровня tier, которые хранятся дольше ttl и ещё не удалены
+func ExpiredMedia(tier string, ttl time.Duration, limit int) ([]models.MediaFile, error) {
+	query := `SELECT id, video_path, thumb_path FROM user_logs
+		WHERE purged_at IS NULL AND video_path IS NOT NULL AND video_path <> ''
+			AND timestamp < NOW() - INTERVAL ? SECOND`
+	args := []interface{}{int64(ttl.Seconds())}
+	if tier == models.TierFavorite {
+		query += ` AND is_favorite = 1`
+	} else {
+		query += ` AND is_favorite = 0 AND media_tier = ?`
+		args = append(args, tier)
+	}
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, limit)
+	return queryMedia(query, args...)
+}
+
+// ListMedia — неудалённые файлы с ID больше afterID, по возрастанию (для переноса между хранилищами)
+func ListMedia(afterID int64, limit int) ([]models.MediaFile, error) {
+	return queryMedia(`SELECT id, video_path, thumb_path FROM user_logs
+		WHERE id > ? AND purged_at IS NULL AND video_path IS NOT NULL AND video_path <> ''
+		ORDER BY id LIMIT ?`, afterID, limit)
+}
+
+// MarkMediaPurged отмечает, что файлы строки удалены по сроку хранения
+func MarkMediaPurged(logID int64) error {
+	_, err := db.DB.Exec(`UPDATE user_logs SET purged_at = NOW() WHERE id = ?`, logID)
+	return err
+}
+
+func queryMedia(query string, args ...interface{}) ([]models.MediaFile, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.MediaFile
+	for rows.Next() {
+		var f models.MediaFile
+		var thumb sql.NullString
+		if err := rows.Scan(&f.LogID, &f.VideoPath, &thumb); err != nil {
+			return nil, err
+		}
+		f.ThumbPath = thumb.String
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
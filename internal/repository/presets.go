@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+const presetColumns = `id, name, title, prompt_prefix, prompt_suffix, aspect_ratio, negative_prompt, model_id, active, created_at`
+
+// SavePreset создаёт пресет или обновляет пресет с тем же именем (и снова включает его)
+func SavePreset(p *models.Preset) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO presets (name, title, prompt_prefix, prompt_suffix, aspect_ratio, negative_prompt, model_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE title = VALUES(title), prompt_prefix = VALUES(prompt_prefix),
+			prompt_suffix = VALUES(prompt_suffix), aspect_ratio = VALUES(aspect_ratio),
+			negative_prompt = VALUES(negative_prompt), model_id = VALUES(model_id), active = 1`,
+		p.Name, p.Title, p.PromptPrefix, p.PromptSuffix, p.AspectRatio, p.NegativePrompt, p.ModelID,
+	)
+	return err
+}
+
+// DisablePreset скрывает пресет; строка остаётся ради истории использования. false — пресета нет
+func DisablePreset(name string) (bool, error) {
+	res, err := db.DB.Exec(`UPDATE presets SET active = 0 WHERE name = ? AND active = 1`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func GetPreset(id int64) (*models.Preset, error) {
+	return scanPreset(db.DB.QueryRow(`SELECT `+presetColumns+` FROM presets WHERE id = ?`, id))
+}
+
+// ListPresets возвращает пресеты в порядке создания; activeOnly — только доступные пользователям
+func ListPresets(activeOnly bool) ([]*models.Preset, error) {
+	query := `SELECT ` + presetColumns + ` FROM presets`
+	if activeOnly {
+		query += ` WHERE active = 1`
+	}
+	rows, err := db.DB.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presets []*models.Preset
+	for rows.Next() {
+		p, err := scanPreset(rows)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, p)
+	}
+	return presets, rows.Err()
+}
+
+// RecordPresetUsage записывает генерацию с пресетом
+func RecordPresetUsage(presetID, userID, jobID int64) error {
+	_, err := db.DB.Exec(`INSERT INTO preset_usage (preset_id, user_id, job_id) VALUES (?, ?, ?)`, presetID, userID, jobID)
+	return err
+}
+
+// PresetUsageStats — использование пресетов по ID пресета
+func PresetUsageStats() (map[int64]models.PresetStats, error) {
+	rows, err := db.DB.Query(`
+		SELECT preset_id, COUNT(*),
+			SUM(created_at >= NOW() - INTERVAL 7 DAY),
+			COUNT(DISTINCT user_id)
+		FROM preset_usage
+		GROUP BY preset_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := map[int64]models.PresetStats{}
+	for rows.Next() {
+		var s models.PresetStats
+		var recent sql.NullInt64
+		if err := rows.Scan(&s.PresetID, &s.Uses, &recent, &s.Users); err != nil {
+			return nil, err
+		}
+		s.Recent = int(recent.Int64)
+		stats[s.PresetID] = s
+	}
+	return stats, rows.Err()
+}
+
+func scanPreset(row rowScanner) (*models.Preset, error) {
+	var p models.Preset
+	var prefix, suffix, aspect, negative, modelID sql.NullString
+	if err := row.Scan(&p.ID, &p.Name, &p.Title, &prefix, &suffix, &aspect, &negative, &modelID, &p.Active, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.PromptPrefix = prefix.String
+	p.PromptSuffix = suffix.String
+	p.AspectRatio = aspect.String
+	p.NegativePrompt = negative.String
+	p.ModelID = modelID.String
+	return &p, nil
+}
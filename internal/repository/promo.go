@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+)
+
+var (
+	ErrPromoNotFound  = errors.New("промокод не найден")
+	ErrPromoRedeemed  = errors.New("промокод уже активирован")
+	ErrPromoExhausted = errors.New("промокод больше не действует")
+)
+
+// SavePromoCode создаёт промокод на credits кредитов или меняет существующий; maxUses 0 — без ограничения
+func SavePromoCode(code string, credits, maxUses int) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO promo_codes (code, credits, max_uses) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE credits = VALUES(credits), max_uses = VALUES(max_uses)`,
+		code, credits, maxUses,
+	)
+	return err
+}
+
+// RedeemPromoCode начисляет пользователю кредиты по промокоду и возвращает их количество.
+// Строка промокода блокируется на время проверки: лимит активаций не превышается, а один
+// пользователь активирует код только раз.
+func RedeemPromoCode(userID int64, code string) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var credits, maxUses, uses int
+	err = tx.QueryRow(`SELECT credits, max_uses, uses FROM promo_codes WHERE code = ? FOR UPDATE`, code).
+		Scan(&credits, &maxUses, &uses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPromoNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var redeemed bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM promo_redemptions WHERE code = ? AND user_id = ?)`,
+		code, userID).Scan(&redeemed); err != nil {
+		return 0, err
+	}
+	if redeemed {
+		return 0, ErrPromoRedeemed
+	}
+	if maxUses > 0 && uses >= maxUses {
+		return 0, ErrPromoExhausted
+	}
+
+	if _, err := tx.Exec(`INSERT INTO promo_redemptions (code, user_id) VALUES (?, ?)`, code, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE promo_codes SET uses = uses + 1 WHERE code = ?`, code); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET credits = credits + ? WHERE telegram_id = ?`, credits, userID); err != nil {
+		return 0, err
+	}
+	return credits, tx.Commit()
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+const cachedResultColumns = `id, user_id, cache_key, job_id, model_id, credits, videos, created_at`
+
+// SaveCachedResult запоминает результат; повторная генерация с тем же ключом заменяет прежний
+func SaveCachedResult(r *models.CachedResult) error {
+	videos, err := json.Marshal(r.Videos)
+	if err != nil {
+		return err
+	}
+	_, err = db.DB.Exec(`
+		INSERT INTO result_cache (user_id, cache_key, job_id, model_id, credits, videos)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE job_id = VALUES(job_id), model_id = VALUES(model_id),
+			credits = VALUES(credits), videos = VALUES(videos), created_at = NOW()`,
+		r.UserID, r.Key, r.JobID, r.ModelID, r.Credits, string(videos),
+	)
+	return err
+}
+
+// FindCachedResult ищет результат пользователя по ключу; nil, nil — если его нет
+func FindCachedResult(userID int64, key string) (*models.CachedResult, error) {
+	r, err := scanCachedResult(db.DB.QueryRow(`SELECT `+cachedResultColumns+` FROM result_cache
+		WHERE user_id = ? AND cache_key = ?`, userID, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
+
+func GetCachedResult(id int64) (*models.CachedResult, error) {
+	return scanCachedResult(db.DB.QueryRow(`SELECT `+cachedResultColumns+` FROM result_cache WHERE id = ?`, id))
+}
+
+// RecordCacheHit записывает повторную выдачу: сколько списано и сколько сэкономлено
+func RecordCacheHit(cacheID, userID int64, charged, saved int) error {
+	_, err := db.DB.Exec(`INSERT INTO cache_hits (cache_id, user_id, credits_charged, credits_saved) VALUES (?, ?, ?, ?)`,
+		cacheID, userID, charged, saved)
+	return err
+}
+
+// CacheHitStats — число повторных выдач и сэкономленные кредиты за всё время
+func CacheHitStats() (hits int, saved int, err error) {
+	var total sql.NullInt64
+	err = db.DB.QueryRow(`SELECT COUNT(*), SUM(credits_saved) FROM cache_hits`).Scan(&hits, &total)
+	return hits, int(total.Int64), err
+}
+
+func scanCachedResult(row rowScanner) (*models.CachedResult, error) {
+	var r models.CachedResult
+	var videos string
+	if err := row.Scan(&r.ID, &r.UserID, &r.Key, &r.JobID, &r.ModelID, &r.Credits, &videos, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(videos), &r.Videos); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/digkill/veo-telegram-bot/internal/db"
+	"github.com/digkill/veo-telegram-bot/internal/models"
+)
+
+const storyColumns = `id, user_id, chat_id, model_id, scenes, crossfade, status, progress_message_id,
+	video_path, log_id, created_at, updated_at`
+
+// CreateStory сохраняет раскадровку в статусе running и проставляет ей ID
+func CreateStory(story *models.Story) error {
+	res, err := db.DB.Exec(`
+		INSERT INTO stories (user_id, chat_id, model_id, scenes, crossfade, status)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		story.UserID, story.ChatID, story.ModelID, story.Scenes, story.Crossfade, models.StoryRunning,
+	)
+	if err != nil {
+		return err
+	}
+	story.ID, err = res.LastInsertId()
+	story.Status = models.StoryRunning
+	return err
+}
+
+func GetStory(storyID int64) (*models.Story, error) {
+	return scanStory(db.DB.QueryRow(`SELECT `+storyColumns+` FROM stories WHERE id = ?`, storyID))
+}
+
+// GetRunningStories — раскадровки, которые ещё не склеены (для продолжения после перезапуска)
+func GetRunningStories() ([]*models.Story, error) {
+	rows, err := db.DB.Query(`SELECT `+storyColumns+` FROM stories WHERE status = ? ORDER BY id`, models.StoryRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stories []*models.Story
+	for rows.Next() {
+		story, err := scanStory(rows)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+	return stories, rows.Err()
+}
+
+func SetStoryProgressMessage(storyID int64, messageID int) error {
+	_, err := db.DB.Exec(`UPDATE stories SET progress_message_id = ? WHERE id = ?`, messageID, storyID)
+	return err
+}
+
+// CompleteStory сохраняет склеенное видео и закрывает раскадровку
+func CompleteStory(storyID int64, videoPath string, logID int64) error {
+	_, err := db.DB.Exec(`UPDATE stories SET status = ?, video_path = ?, log_id = ? WHERE id = ?`,
+		models.StoryDone, videoPath, nullInt64(logID), storyID)
+	return err
+}
+
+func FailStory(storyID int64) error {
+	_, err := db.DB.Exec(`UPDATE stories SET status = ? WHERE id = ?`, models.StoryFailed, storyID)
+	return err
+}
+
+// GetSceneJobs возвращает последнюю задачу каждой сцены (повтор сцены создаёт новую задачу), по порядку сцен
+func GetSceneJobs(storyID int64) (map[int]*models.GenerationJob, error) {
+	rows, err := db.DB.Query(`SELECT `+jobColumns+` FROM generation_jobs WHERE story_id = ? ORDER BY id`, storyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := map[int]*models.GenerationJob{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs[job.SceneIndex] = job
+	}
+	return jobs, rows.Err()
+}
+
+func scanStory(row rowScanner) (*models.Story, error) {
+	var story models.Story
+	var progress sql.NullInt64
+	var videoPath sql.NullString
+	var logID sql.NullInt64
+
+	err := row.Scan(&story.ID, &story.UserID, &story.ChatID, &story.ModelID, &story.Scenes, &story.Crossfade,
+		&story.Status, &progress, &videoPath, &logID, &story.CreatedAt, &story.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	story.ProgressMessageID = int(progress.Int64)
+	story.VideoPath = videoPath.String
+	story.LogID = logID.Int64
+	return &story, nil
+}
@@ -74,3 +74,28 @@ func GetUserByID(userID int64) (models.User, error) {
 	}
 	return user, nil
 }
+
+// GetUserModel возвращает выбранную пользователем модель; "" — модель по умолчанию
+func GetUserModel(userID int64) (string, error) {
+	var modelID sql.NullString
+	err := db.DB.QueryRow("SELECT model_id FROM users WHERE telegram_id = ?", userID).Scan(&modelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return modelID.String, err
+}
+
+func SetUserModel(userID int64, modelID string) error {
+	_, err := db.DB.Exec(`UPDATE users SET model_id = ? WHERE telegram_id = ?`, modelID, userID)
+	return err
+}
+
+// IsUserBlocked — заблокирован ли пользователь (users.is_blocked); несуществующий пользователь не заблокирован
+func IsUserBlocked(telegramID int64) (bool, error) {
+	var blocked bool
+	err := db.DB.QueryRow("SELECT is_blocked FROM users WHERE telegram_id = ?", telegramID).Scan(&blocked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return blocked, err
+}
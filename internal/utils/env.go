@@ -3,6 +3,7 @@ package utils
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -20,3 +21,20 @@ func MustGetEnv(key string) string {
 	}
 	return val
 }
+
+// GetEnv возвращает значение переменной окружения или значение по умолчанию
+func GetEnv(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// GetEnvInt возвращает положительное целое из переменной окружения или значение по умолчанию
+func GetEnvInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
@@ -1,22 +1,29 @@
 package utils
 
 import (
-	"encoding/base64"
+	"fmt"
 	"io"
 	"net/http"
 )
 
-func DownloadAndEncodeImage(url string) (string, error) {
+// DownloadImage скачивает файл целиком, но не больше limit байт
+func DownloadImage(url string, limit int64) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	imgBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: HTTP %d", resp.StatusCode)
 	}
 
-	return base64.StdEncoding.EncodeToString(imgBytes), nil
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("файл больше %d МБ", limit>>20)
+	}
+	return data, nil
 }
@@ -0,0 +1,128 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenFunc возвращает OAuth access token для заголовка Authorization
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Client — HTTP-клиент Vertex AI для моделей Veo
+type Client struct {
+	BaseURL    string // например: https://us-central1-aiplatform.googleapis.com
+	ProjectID  string
+	LocationID string
+	HTTPClient *http.Client
+	Token      TokenFunc
+}
+
+// ErrToken — не удалось получить access token; повтор в другом регионе не поможет
+var ErrToken = errors.New("vertex: не удалось получить access token")
+
+// APIError — ответ Vertex AI с кодом, отличным от 2xx
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("vertex: HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("vertex: HTTP %d", e.StatusCode)
+}
+
+func NewClient(baseURL, projectID, locationID string, token TokenFunc) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		ProjectID:  projectID,
+		LocationID: locationID,
+		HTTPClient: &http.Client{Timeout: 2 * time.Minute},
+		Token:      token,
+	}
+}
+
+func (c *Client) modelURL(modelID, method string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		c.BaseURL, c.ProjectID, c.LocationID, modelID, method)
+}
+
+// PredictLongRunning запускает генерацию и возвращает операцию с её именем
+func (c *Client) PredictLongRunning(ctx context.Context, modelID string, body []byte) (*Operation, error) {
+	var op Operation
+	if err := c.post(ctx, c.modelURL(modelID, "predictLongRunning"), body, &op); err != nil {
+		return nil, err
+	}
+	if op.Name == "" {
+		return nil, fmt.Errorf("vertex: в ответе нет имени операции")
+	}
+	return &op, nil
+}
+
+// FetchPredictOperation возвращает текущее состояние операции
+func (c *Client) FetchPredictOperation(ctx context.Context, modelID, operationName string) (*Operation, error) {
+	body, err := json.Marshal(fetchOperationRequest{OperationName: operationName})
+	if err != nil {
+		return nil, err
+	}
+	var op Operation
+	if err := c.post(ctx, c.modelURL(modelID, "fetchPredictOperation"), body, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// CancelOperation просит Vertex AI остановить операцию (google.longrunning Operations.CancelOperation).
+// Отмена не гарантирована: операция может успеть завершиться.
+func (c *Client) CancelOperation(ctx context.Context, operationName string) error {
+	var empty struct{}
+	return c.post(ctx, fmt.Sprintf("%s/v1/%s:cancel", c.BaseURL, operationName), []byte("{}"), &empty)
+}
+
+func (c *Client) post(ctx context.Context, url string, body []byte, out interface{}) error {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrToken, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(raw)}
+		var errBody apiErrorBody
+		if json.Unmarshal(raw, &errBody) == nil && errBody.Error != nil {
+			apiErr.Message = errBody.Error.Message
+		}
+		return apiErr
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("vertex: невалидный JSON в ответе: %w", err)
+	}
+	return nil
+}
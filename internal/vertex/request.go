@@ -0,0 +1,57 @@
+package vertex
+
+import "strings"
+
+// Семейства моделей Veo: у veo-3 есть звук и выбор разрешения
+const (
+	FamilyVeo2 = "veo2"
+	FamilyVeo3 = "veo3"
+)
+
+// FamilyOf определяет семейство по ID модели
+func FamilyOf(modelID string) string {
+	if strings.HasPrefix(modelID, "veo-2") {
+		return FamilyVeo2
+	}
+	return FamilyVeo3
+}
+
+// PredictRequest — тело predictLongRunning
+type PredictRequest struct {
+	Instances  []Instance  `json:"instances"`
+	Parameters interface{} `json:"parameters"` // *Veo2Parameters или *Veo3Parameters
+}
+
+type Instance struct {
+	Prompt    string `json:"prompt"`
+	Image     *Image `json:"image,omitempty"`
+	LastFrame *Image `json:"lastFrame,omitempty"` // конечный кадр для интерполяции; только Veo 2
+}
+
+// Image — входное изображение: inline base64 или ссылка на GCS
+type Image struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded,omitempty"`
+	GcsURI             string `json:"gcsUri,omitempty"`
+	MimeType           string `json:"mimeType,omitempty"`
+}
+
+// Veo2Parameters — параметры, общие для всех моделей Veo
+type Veo2Parameters struct {
+	AspectRatio           string  `json:"aspectRatio,omitempty"`
+	SampleCount           int     `json:"sampleCount,omitempty"`
+	DurationSeconds       int     `json:"durationSeconds,omitempty"`
+	Seed                  *uint32 `json:"seed,omitempty"`
+	NegativePrompt        string  `json:"negativePrompt,omitempty"`
+	PersonGeneration      string  `json:"personGeneration,omitempty"`
+	EnablePromptRewriting bool    `json:"enablePromptRewriting"`
+	AddWatermark          bool    `json:"addWatermark"`
+	IncludeRaiReason      bool    `json:"includeRaiReason"`
+	StorageURI            string  `json:"storageUri,omitempty"`
+}
+
+// Veo3Parameters — параметры veo-3: плюс звук и разрешение
+type Veo3Parameters struct {
+	Veo2Parameters
+	GenerateAudio bool   `json:"generateAudio"`
+	Resolution    string `json:"resolution,omitempty"`
+}
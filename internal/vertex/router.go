@@ -0,0 +1,313 @@
+package vertex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	baseCooldown = 30 * time.Second
+	maxCooldown  = 10 * time.Minute
+
+	// опрос операции повторяется в её регионе: другой регион об операции ничего не знает
+	fetchAttempts   = 3
+	fetchRetryDelay = time.Second
+)
+
+// Region — проект и location Vertex AI с весом для распределения запросов
+// и собственным состоянием здоровья
+type Region struct {
+	Client *Client
+	Weight int
+
+	mu        sync.Mutex
+	failures  int       // ошибок подряд
+	downUntil time.Time // до этого момента регион выбирается только в крайнем случае
+}
+
+// RegionStatus — снимок состояния региона для метрик и админских команд
+type RegionStatus struct {
+	Name      string    `json:"name"`
+	Weight    int       `json:"weight"`
+	Healthy   bool      `json:"healthy"`
+	Failures  int       `json:"failures"`
+	DownUntil time.Time `json:"down_until"`
+}
+
+func NewRegion(client *Client, weight int) *Region {
+	if weight < 1 {
+		weight = 1
+	}
+	return &Region{Client: client, Weight: weight}
+}
+
+// Name — «project/location»; так регион записывается в задачу и логи
+func (r *Region) Name() string {
+	return r.Client.ProjectID + "/" + r.Client.LocationID
+}
+
+func (r *Region) healthy(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !now.Before(r.downUntil)
+}
+
+// markFailure выводит регион из ротации; каждая следующая ошибка подряд удваивает паузу
+func (r *Region) markFailure(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	cooldown := baseCooldown << min(r.failures-1, 10)
+	if cooldown > maxCooldown {
+		cooldown = maxCooldown
+	}
+	r.downUntil = now.Add(cooldown)
+}
+
+func (r *Region) markSuccess() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = 0
+	r.downUntil = time.Time{}
+}
+
+// Router распределяет запуски генераций по регионам с учётом весов и переключается
+// на следующий регион при исчерпании квоты (429) или ошибках 5xx
+type Router struct {
+	Regions []*Region
+	// OnRegionError вызывается, когда регион отказал и запрос уходит в следующий
+	OnRegionError func(region *Region, err error)
+}
+
+func NewRouter(regions ...*Region) *Router {
+	return &Router{Regions: regions}
+}
+
+// PredictLongRunning запускает генерацию в первом доступном регионе.
+// Возвращает операцию и регион, который её принял: опрашивать операцию нужно там же.
+func (r *Router) PredictLongRunning(ctx context.Context, modelID string, body []byte) (*Operation, *Region, error) {
+	var lastErr error
+	for _, region := range r.order(time.Now()) {
+		op, err := region.Client.PredictLongRunning(ctx, modelID, body)
+		if err == nil {
+			region.markSuccess()
+			return op, region, nil
+		}
+		if ctx.Err() != nil || !Retriable(err) {
+			return nil, region, err
+		}
+
+		region.markFailure(time.Now())
+		if r.OnRegionError != nil {
+			r.OnRegionError(region, err)
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("vertex: не настроено ни одного региона")
+	}
+	return nil, nil, lastErr
+}
+
+// FetchPredictOperation опрашивает операцию в регионе, который её принял.
+// regionName — имя из задачи; у задач без него регион ищется по имени операции.
+func (r *Router) FetchPredictOperation(ctx context.Context, regionName, modelID, operationName string) (*Operation, error) {
+	region := r.Region(regionName)
+	if region == nil {
+		region = r.RegionOf(operationName)
+	}
+	if region == nil {
+		return nil, errors.New("vertex: не настроено ни одного региона")
+	}
+
+	var err error
+	for attempt := 0; attempt < fetchAttempts; attempt++ {
+		if attempt > 0 && !sleepContext(ctx, fetchRetryDelay<<(attempt-1)) {
+			break
+		}
+		var op *Operation
+		op, err = region.Client.FetchPredictOperation(ctx, modelID, operationName)
+		if err == nil {
+			return op, nil
+		}
+		if ctx.Err() != nil || !Retriable(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// sleepContext ждёт d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// CancelOperation отменяет операцию в регионе, который её принял
+func (r *Router) CancelOperation(ctx context.Context, regionName, operationName string) error {
+	region := r.Region(regionName)
+	if region == nil {
+		region = r.RegionOf(operationName)
+	}
+	if region == nil {
+		return errors.New("vertex: не настроено ни одного региона")
+	}
+	return region.Client.CancelOperation(ctx, operationName)
+}
+
+// Region — регион по имени «project/location»; nil, если такого нет
+func (r *Router) Region(name string) *Region {
+	for _, region := range r.Regions {
+		if region.Name() == name {
+			return region
+		}
+	}
+	return nil
+}
+
+// RegionOf находит регион по location из имени операции «projects/P/locations/L/…»
+// (в имени операции вместо ID проекта бывает его номер); если не нашёлся — первый настроенный
+func (r *Router) RegionOf(operationName string) *Region {
+	if len(r.Regions) == 0 {
+		return nil
+	}
+	parts := strings.Split(operationName, "/")
+	if len(parts) >= 4 && parts[0] == "projects" && parts[2] == "locations" {
+		for _, region := range r.Regions {
+			if region.Client.LocationID == parts[3] {
+				return region
+			}
+		}
+	}
+	return r.Regions[0]
+}
+
+// Status — состояние всех регионов
+func (r *Router) Status() []RegionStatus {
+	now := time.Now()
+	statuses := make([]RegionStatus, 0, len(r.Regions))
+	for _, region := range r.Regions {
+		region.mu.Lock()
+		statuses = append(statuses, RegionStatus{
+			Name:      region.Name(),
+			Weight:    region.Weight,
+			Healthy:   !now.Before(region.downUntil),
+			Failures:  region.failures,
+			DownUntil: region.downUntil,
+		})
+		region.mu.Unlock()
+	}
+	return statuses
+}
+
+// order — порядок попыток: здоровые регионы взвешенным случайным выбором,
+// затем больные — те, что раньше вернутся в строй, первыми
+func (r *Router) order(now time.Time) []*Region {
+	var healthy, down []*Region
+	for _, region := range r.Regions {
+		if region.healthy(now) {
+			healthy = append(healthy, region)
+		} else {
+			down = append(down, region)
+		}
+	}
+
+	ordered := make([]*Region, 0, len(r.Regions))
+	for len(healthy) > 0 {
+		total := 0
+		for _, region := range healthy {
+			total += region.Weight
+		}
+		n := rand.Intn(total)
+		for i, region := range healthy {
+			if n < region.Weight {
+				ordered = append(ordered, region)
+				healthy = append(healthy[:i], healthy[i+1:]...)
+				break
+			}
+			n -= region.Weight
+		}
+	}
+
+	sort.Slice(down, func(i, j int) bool {
+		down[i].mu.Lock()
+		a := down[i].downUntil
+		down[i].mu.Unlock()
+		down[j].mu.Lock()
+		b := down[j].downUntil
+		down[j].mu.Unlock()
+		return a.Before(b)
+	})
+	return append(ordered, down...)
+}
+
+// Retriable — временная ошибка, после которой запрос имеет смысл повторить:
+// квота (429), ошибка сервера (5xx) или сбой сети. Ошибки токена, отмена и невалидный ответ — не временные.
+func Retriable(err error) bool {
+	if errors.Is(err, ErrToken) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RegionSpec — регион из конфигурации VERTEX_REGIONS
+type RegionSpec struct {
+	ProjectID  string
+	LocationID string
+	Weight     int
+}
+
+// ParseRegions разбирает список «[project/]location[=weight]» через запятую,
+// например «us-central1=3,other-project/europe-west4=1». Проект по умолчанию — defaultProject.
+func ParseRegions(spec, defaultProject string) ([]RegionSpec, error) {
+	var specs []RegionSpec
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		s := RegionSpec{ProjectID: defaultProject, Weight: 1}
+		if name, weight, ok := strings.Cut(item, "="); ok {
+			n, err := strconv.Atoi(weight)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("регион %q: вес должен быть положительным целым", item)
+			}
+			item, s.Weight = name, n
+		}
+		if project, location, ok := strings.Cut(item, "/"); ok {
+			s.ProjectID, item = project, location
+		}
+		s.LocationID = item
+
+		if s.ProjectID == "" || s.LocationID == "" {
+			return nil, fmt.Errorf("регион %q: нужны проект и location", item)
+		}
+		specs = append(specs, s)
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("список регионов пуст")
+	}
+	return specs, nil
+}
@@ -0,0 +1,42 @@
+package vertex
+
+import "encoding/json"
+
+// Operation — long-running операция Vertex AI (predictLongRunning / fetchPredictOperation)
+type Operation struct {
+	Name     string           `json:"name"`
+	Done     bool             `json:"done,omitempty"`
+	Error    *Status          `json:"error,omitempty"`
+	Response *PredictResponse `json:"response,omitempty"`
+}
+
+// Status — ошибка операции в формате google.rpc.Status
+type Status struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// PredictResponse — результат завершённой генерации
+type PredictResponse struct {
+	Type                    string   `json:"@type,omitempty"`
+	RaiMediaFilteredCount   int      `json:"raiMediaFilteredCount,omitempty"`
+	RaiMediaFilteredReasons []string `json:"raiMediaFilteredReasons,omitempty"`
+	Videos                  []Video  `json:"videos,omitempty"`
+}
+
+// Video — одно сгенерированное видео: либо inline base64, либо ссылка на GCS
+type Video struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded,omitempty"`
+	GcsURI             string `json:"gcsUri,omitempty"`
+	MimeType           string `json:"mimeType,omitempty"`
+}
+
+type fetchOperationRequest struct {
+	OperationName string `json:"operationName"`
+}
+
+// apiErrorBody — тело ошибки HTTP-уровня, которое отдаёт Google API
+type apiErrorBody struct {
+	Error *Status `json:"error"`
+}